@@ -1,19 +1,157 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/charmbracelet/x/term"
 	"github.com/nextthang/lurkmode/internal/app"
+	"github.com/nextthang/lurkmode/internal/config"
+	"github.com/nextthang/lurkmode/internal/replay"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: lurkmode <channel_name>")
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replayMain(os.Args[2:])
+		return
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: lurkmode [flags] <channel_name> [channel_name...]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lurkmode replay [flags] <file> [channel_name...]")
+		flag.PrintDefaults()
+	}
+	applyFlags := configFlags(flag.CommandLine)
+	output := outputFlags(flag.CommandLine)
+	server := flag.String("server", "", "host:port of the IRC server to connect to instead of Twitch")
+	noTLS := flag.Bool("no-tls", false, "connect to the IRC server without TLS")
+	caFile := flag.String("ca-file", "", "PEM file with the certificates to trust for the IRC server")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	applyFlags(&cfg)
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *noTLS {
+		cfg.NoTLS = true
+	}
+	if *caFile != "" {
+		cfg.CAFile = *caFile
+	}
+
+	if err := app.Run(cfg, output(), flag.Args()...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func replayMain(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lurkmode replay [flags] <file> [channel_name...]")
+		fmt.Fprintln(flags.Output(), "Plays back raw IRC lines or JSON Lines chat logs, showing every channel unless given.")
+		flags.PrintDefaults()
+	}
+	speed := flags.Float64("speed", 1, "playback speed relative to the recording, 0 plays everything at once")
+	step := flags.Bool("step", false, "wait for space (enter with -plain or -json) before showing the next message")
+	applyFlags := configFlags(flags)
+	output := outputFlags(flags)
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
 		os.Exit(1)
 	}
-	if err := app.Run(os.Args[1]); err != nil {
+
+	cfg := loadConfig()
+	applyFlags(&cfg)
+
+	options := replay.Options{Speed: *speed, Step: *step}
+	if err := app.Replay(cfg, output(), flags.Arg(0), options, flags.Args()[1:]...); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// configFlags registers the flags that override the config file and returns
+// a function applying them once parsed.
+func configFlags(flags *flag.FlagSet) func(*config.Config) {
+	emoteMode := flags.String("emotes", "", "how to render emotes: auto, sixel, kitty or text")
+	filterExpression := flags.String("filter", "", "only show messages matching a filter, e.g. \"badge:mod -bot\"")
+	historySize := flags.Int("history", 0, "number of messages to keep in memory per channel (default 200)")
+	historyOnDisk := flags.Bool("history-disk", false, "keep older messages on disk so that they can be loaded back with o")
+	logDir := flags.String("log-dir", "", "log chat to daily files per channel in this directory")
+	logFormat := flags.String("log-format", "", "format of the chat logs: text, jsonl or irc (default text)")
+	highlights := flags.String("highlight", "", "comma separated words or /regexes/ to highlight besides your name")
+	highlightUsers := flags.String("highlight-users", "", "comma separated users whose messages to highlight")
+	notify := flags.String("notify", "", "notify of highlights while not looking: bell, osc9 or osc777")
+	themeName := flags.String("theme", "", "dark, light, high-contrast, monochrome or the name or path of a theme file (default dark)")
+
+	return func(cfg *config.Config) {
+		if *emoteMode != "" {
+			cfg.Emotes = *emoteMode
+		}
+		if *filterExpression != "" {
+			cfg.Filter = *filterExpression
+		}
+		if *historySize > 0 {
+			cfg.History = *historySize
+		}
+		if *historyOnDisk {
+			cfg.HistoryOnDisk = true
+		}
+		if *logDir != "" {
+			cfg.LogDir = *logDir
+		}
+		if *logFormat != "" {
+			cfg.LogFormat = *logFormat
+		}
+		if *highlights != "" {
+			cfg.Highlights = append(cfg.Highlights, strings.Split(*highlights, ",")...)
+		}
+		if *highlightUsers != "" {
+			cfg.HighlightUsers = append(cfg.HighlightUsers, strings.Split(*highlightUsers, ",")...)
+		}
+		if *notify != "" {
+			cfg.Notify = *notify
+		}
+		if *themeName != "" {
+			cfg.Theme = *themeName
+		}
+	}
+}
+
+// outputFlags registers the flags choosing between the TUI and writing lines
+// to stdout, which is the default when stdout is not a terminal.
+func outputFlags(flags *flag.FlagSet) func() app.Output {
+	plain := flags.Bool("plain", false, "write messages as lines of text to stdout instead of showing the TUI")
+	json := flags.Bool("json", false, "write messages as JSON lines to stdout instead of showing the TUI")
+
+	return func() app.Output {
+		switch {
+		case *json:
+			return app.OutputJSON
+		case *plain || !term.IsTerminal(os.Stdout.Fd()):
+			return app.OutputPlain
+		default:
+			return app.OutputTUI
+		}
+	}
+}
+
+func loadConfig() config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
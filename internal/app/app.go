@@ -1,50 +1,699 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/viewport"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/chatlog"
+	"github.com/nextthang/lurkmode/internal/config"
+	"github.com/nextthang/lurkmode/internal/emotes"
+	"github.com/nextthang/lurkmode/internal/filter"
+	"github.com/nextthang/lurkmode/internal/history"
+	"github.com/nextthang/lurkmode/internal/ignore"
 	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/replay"
+	"github.com/nextthang/lurkmode/internal/theme"
 	"github.com/nextthang/lurkmode/internal/twitch"
+	"github.com/nextthang/lurkmode/pkg/queue"
 	"github.com/nextthang/lurkmode/pkg/ringbuffer"
 )
 
+type tab struct {
+	channelName string
+	messages    *ringbuffer.RingBuffer[message.Message]
+	unread      int
+	selected    message.Message
+	// pending holds the messages that arrived while the view was paused and
+	// adding them would have evicted a message on screen.
+	pending []message.Message
+	// older holds the messages loaded back from the history store, starting
+	// at line olderFrom. Once something was loaded, evicted messages are kept
+	// here too, up to olderLimit.
+	older      []message.Message
+	olderFrom  int
+	olderLimit int
+	// rendered caches how the messages in history were rendered last.
+	rendered map[message.Message]renderedMessage
+}
+
+func (m model) newTab(channelName string) *tab {
+	t := &tab{
+		channelName: strings.ToLower(channelName),
+		messages:    ringbuffer.NewBuffer[message.Message](m.historySize),
+	}
+	if store := m.historyStore; store != nil {
+		t.messages.OnEvict(func(msg message.Message) {
+			if err := store.Append(t.channelName, msg.Raw()); err != nil {
+				log.Printf("Failed to store message: %v", err)
+			}
+			if t.older != nil {
+				t.older = append(t.older, msg)
+				t.trimOlder()
+			}
+		})
+	}
+	return t
+}
+
+// trimOlder drops the oldest of the older messages once there are more than
+// olderLimit. They are in the history store, so they can be loaded again.
+func (t *tab) trimOlder() {
+	excess := len(t.older) - t.olderLimit
+	if excess <= 0 {
+		return
+	}
+	for _, msg := range t.older[:excess] {
+		// Messages without a raw line, like our own, were never stored.
+		if msg.Raw() != "" {
+			t.olderFrom++
+		}
+	}
+	t.older = t.older[excess:]
+}
+
+// history returns every message of the tab that is in memory, oldest first.
+func (t *tab) history() []message.Message {
+	return slices.Concat(t.older, t.messages.Get())
+}
+
+// chatClient is where the messages come from, Twitch or a replay.
+type chatClient interface {
+	Connect() error
+	Disconnect() error
+	Authenticated() bool
+	Say(channel, text string) (message.Message, error)
+	AddChannel(channel string)
+	RemoveChannel(channel string)
+}
+
+// statusReporter is implemented by clients that report the state of their
+// connection.
+type statusReporter interface {
+	OnStatus(func(twitch.Status))
+}
+
+type connectionStatusMsg twitch.Status
+
+// stepper is implemented by clients that can deliver messages one at a time
+// on request.
+type stepper interface {
+	Stepping() bool
+	Step()
+}
+
 type model struct {
-	ready        bool
-	viewport     viewport.Model
-	channelName  string
-	messageChan  <-chan message.Message
-	messages     *ringbuffer.RingBuffer[message.Message]
-	twitchClient *twitch.Client
-	footer       footer
-	header       header
-	renderTime   bool
-	shuttingDown bool
-}
-
-// TODO: We should probably make this configurable.
-const historySize = 200
-
-func (m model) receiveMessage() tea.Cmd {
-	return func() tea.Msg {
-		for {
-			msg := <-m.messageChan
-			if msg != nil {
-				return msg
+	ready         bool
+	width         int
+	height        int
+	viewport      viewport.Model
+	tabs          []*tab
+	activeTab     int
+	messageQueue  *queue.Queue[message.Message]
+	client        chatClient
+	prompt        prompt
+	composer      composer
+	footer        footer
+	header        header
+	renderOptions message.RenderOptions
+	search        search
+	filter        *filter.Filter
+	ignores       *ignore.List
+	// hidden counts the messages the ignore list kept out of the chat.
+	hidden       int
+	highlighter  highlighter
+	mentions     mentions
+	notification notification
+	// focused is whether the terminal has focus, as far as it tells us.
+	focused     bool
+	lineOffsets []int
+	// joined holds the channels we receive messages of, or is nil if the
+	// client does not tell.
+	joined map[string]bool
+	// renderGeneration invalidates every cached rendering when increased.
+	renderGeneration int
+	theme            theme.Theme
+	themeName        string
+	newMessages      int
+	historySize      int
+	historyStore     *history.Store
+	chatLogger       *chatlog.Logger
+	shuttingDown     bool
+}
+
+const (
+	// messageQueueSize is how many messages may wait for the UI before the
+	// oldest are dropped.
+	messageQueueSize = 1000
+	// frameDuration is how long to wait for more messages after one arrived
+	// before rendering them all at once.
+	frameDuration      = 16 * time.Millisecond
+	defaultHistorySize = 200
+	olderPageSize      = 100
+	minMentionsWidth   = 24
+)
+
+func (m model) currentTab() *tab {
+	return m.tabs[m.activeTab]
+}
+
+func (m model) findTab(channelName string) (int, *tab) {
+	for i, t := range m.tabs {
+		if t.channelName == channelName {
+			return i, t
+		}
+	}
+	return -1, nil
+}
+
+func (m *model) switchTab(index int) {
+	m.flushPending()
+	m.activeTab = (index + len(m.tabs)) % len(m.tabs)
+	m.currentTab().unread = 0
+	m.updateHeader()
+	m.refreshViewport()
+	if !m.scrollToSelection() {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *model) joinChannel(channelName string) {
+	channelName = strings.TrimPrefix(strings.ToLower(channelName), "#")
+	if channelName == "" {
+		return
+	}
+	if index, _ := m.findTab(channelName); index >= 0 {
+		m.switchTab(index)
+		return
+	}
+
+	m.client.AddChannel(channelName)
+	m.tabs = append(m.tabs, m.newTab(channelName))
+	m.switchTab(len(m.tabs) - 1)
+}
+
+func (m *model) partChannel() {
+	// There is nothing to lurk in without at least one channel.
+	if len(m.tabs) < 2 {
+		return
+	}
+
+	m.client.RemoveChannel(m.currentTab().channelName)
+	m.tabs = slices.Delete(m.tabs, m.activeTab, m.activeTab+1)
+	m.switchTab(min(m.activeTab, len(m.tabs)-1))
+}
+
+func (m *model) updateHeader() {
+	tabs := make([]headerTab, len(m.tabs))
+	for i, t := range m.tabs {
+		tabs[i] = headerTab{
+			name:   t.channelName,
+			unread: t.unread,
+			joined: m.joined == nil || m.joined[t.channelName],
+		}
+	}
+	m.header.SetTabs(tabs, m.activeTab)
+	m.header.SetMentions(m.mentions.unseen)
+}
+
+func (m *model) updateLayout() {
+	height := m.height - lipgloss.Height(m.header.View()) - lipgloss.Height(m.footerView())
+	if m.composer.enabled {
+		height -= lipgloss.Height(m.composer.View())
+	}
+
+	width := m.width
+	if m.mentions.open {
+		mentionsWidth := min(max(m.width/3, minMentionsWidth), m.width/2)
+		m.mentions.SetSize(mentionsWidth, height)
+		width -= mentionsWidth
+	}
+
+	m.viewport.SetWidth(width)
+	m.viewport.SetHeight(height)
+	m.composer.SetWidth(m.width)
+}
+
+func (m *model) addMessage(msg message.Message) {
+	m.addMessages([]message.Message{msg})
+}
+
+// addMessages adds a batch of messages, rendering the chat only once for all
+// of them. It returns the command notifying about highlights the user might
+// not see.
+func (m *model) addMessages(msgs []message.Message) tea.Cmd {
+	paused := m.paused()
+	top, topIndex := m.topAnchor()
+	unread := false
+	added := false
+	var unnoticed []message.Message
+
+	for _, msg := range msgs {
+		if m.chatLogger != nil {
+			m.chatLogger.Log(msg)
+		}
+		if m.ignores.Matches(msg) {
+			m.hidden++
+			continue
+		}
+
+		index, t := m.findTab(msg.ChannelName())
+		if t == nil {
+			// We might still receive messages for a channel we just parted.
+			continue
+		}
+
+		if moderation, ok := msg.(message.Moderation); ok {
+			applyModeration(t, moderation)
+		}
+		if m.highlighter.Matches(msg) {
+			m.mentions.Add(msg)
+			unread = true
+			if !m.focused || paused || index != m.activeTab {
+				unnoticed = append(unnoticed, msg)
+			}
+		}
+
+		if index != m.activeTab {
+			t.messages.Add(msg)
+			if m.visible(msg) {
+				t.unread++
+				unread = true
+			}
+			continue
+		}
+
+		added = true
+		if !paused {
+			t.messages.Add(msg)
+			continue
+		}
+		if m.visible(msg) {
+			m.newMessages++
+		}
+		if m.holdBack(t, topIndex) {
+			t.pending = append(t.pending, msg)
+			continue
+		}
+		if t.older == nil && t.messages.Full() {
+			// The oldest message goes, moving the top of the screen up.
+			topIndex--
+		}
+		t.messages.Add(msg)
+	}
+
+	if unread {
+		m.updateHeader()
+	}
+	if added {
+		m.refreshViewport()
+		if paused {
+			m.restoreAnchor(top)
+		} else {
+			m.viewport.GotoBottom()
+		}
+		m.updateNotice()
+	}
+	return m.notify(unnoticed)
+}
+
+// notify draws attention to highlights, once per batch.
+func (m model) notify(msgs []message.Message) tea.Cmd {
+	if len(msgs) == 0 || m.notification == notifyNone {
+		return nil
+	}
+
+	msg := msgs[len(msgs)-1]
+	title := "LurkMode: #" + msg.ChannelName()
+	body := msg.Sender().DisplayName + ": " + msg.Text()
+	if len(msgs) > 1 {
+		body = fmt.Sprintf("%s (and %d more)", body, len(msgs)-1)
+	}
+	return tea.Raw(m.notification.sequence(title, body))
+}
+
+func (m *model) toggleMentions() {
+	m.mentions.Toggle()
+	m.updateHeader()
+	m.updateLayout()
+	m.refreshViewport()
+	if !m.scrollToSelection() {
+		m.viewport.GotoBottom()
+	}
+}
+
+func applyModeration(t *tab, moderation message.Moderation) {
+	for _, msg := range slices.Concat(t.older, t.messages.Get(), t.pending) {
+		if deletable, ok := msg.(message.Deletable); ok && moderation.Affects(msg) {
+			deletable.MarkDeleted()
+			delete(t.rendered, msg)
+		}
+	}
+}
+
+func (m *model) setConnectionStatus(status twitch.Status) {
+	switch {
+	case status.Channel != "":
+		m.joined[status.Channel] = true
+	case status.State != twitch.StateConnected:
+		clear(m.joined)
+		fallthrough
+	default:
+		m.header.SetConnection(status.String())
+	}
+	m.updateHeader()
+}
+
+func (m *model) setFilter(expression string) {
+	messageFilter, err := filter.Parse(expression)
+	if err != nil && !errors.Is(err, filter.ErrEmptyFilter) {
+		m.footer.SetStatus(err.Error())
+		return
+	}
+
+	m.filter = messageFilter
+	m.header.SetFilter(messageFilter.String())
+	if selected := m.currentTab().selected; selected != nil && !m.visible(selected) {
+		m.currentTab().selected = nil
+	}
+	m.refreshViewport()
+	if !m.scrollToSelection() {
+		m.viewport.GotoBottom()
+	}
+	m.updateNotice()
+}
+
+// visible reports whether a message shows in the chat, which is not the case
+// if it is filtered out or ignored.
+func (m model) visible(msg message.Message) bool {
+	return m.filter.Match(msg) && !m.ignores.Matches(msg)
+}
+
+func indexOf(history []message.Message, msg message.Message) int {
+	if msg == nil {
+		return -1
+	}
+	return slices.Index(history, msg)
+}
+
+// scrollToSelection makes sure the selected message is on screen. It reports
+// false if there is no selection.
+func (m *model) scrollToSelection() bool {
+	index := indexOf(m.currentTab().history(), m.currentTab().selected)
+	if index < 0 || index >= len(m.lineOffsets) || m.lineOffsets[index] < 0 {
+		return false
+	}
+	m.viewport.EnsureVisible(m.lineOffsets[index], 0, 0)
+	return true
+}
+
+func (m *model) setSelection(msg message.Message) {
+	m.currentTab().selected = msg
+	m.refreshViewport()
+	if !m.scrollToSelection() {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *model) moveSelection(delta int) {
+	history := m.currentTab().history()
+	if len(history) == 0 {
+		return
+	}
+
+	index := indexOf(history, m.currentTab().selected)
+	if index < 0 {
+		// The selection starts at the newest message.
+		index = len(history)
+		if delta > 0 {
+			return
+		}
+	}
+	for index += delta; index >= 0 && index < len(history); index += delta {
+		if m.visible(history[index]) {
+			m.setSelection(history[index])
+			return
+		}
+	}
+	if delta > 0 {
+		m.setSelection(nil)
+	}
+}
+
+// jumpToParent selects the message that the selected reply, or the newest
+// reply if nothing is selected, is replying to.
+func (m *model) jumpToParent() {
+	history := m.currentTab().history()
+
+	var parentID string
+	if reply, ok := m.currentTab().selected.(message.Reply); ok {
+		parentID = reply.ParentID()
+	} else {
+		for _, msg := range slices.Backward(history) {
+			if reply, ok := msg.(message.Reply); ok && reply.ParentID() != "" {
+				parentID = reply.ParentID()
+				break
+			}
+		}
+	}
+	if parentID == "" {
+		m.footer.SetStatus("Not a reply")
+		return
+	}
+
+	for _, msg := range history {
+		if reply, ok := msg.(message.Reply); ok && reply.MessageID() == parentID {
+			if !m.visible(msg) {
+				m.footer.SetStatus("The parent message is hidden by the filter or ignored")
+				return
+			}
+			m.setSelection(msg)
+			return
+		}
+	}
+	m.footer.SetStatus("The parent message is no longer in the history")
+}
+
+func (m *model) startSearch(query string) {
+	if query == "" {
+		m.clearSearch()
+		return
+	}
+
+	s, err := parseSearch(query)
+	if err != nil {
+		m.footer.SetStatus(err.Error())
+		return
+	}
+	m.search = s
+	m.renderOptions.Highlight = s.pattern
+	m.currentTab().selected = nil
+	m.refreshViewport()
+	m.jumpToMatch(-1)
+}
+
+func (m *model) clearSearch() {
+	m.search = search{}
+	m.renderOptions.Highlight = nil
+	m.setSelection(nil)
+	m.updateNotice()
+}
+
+// jumpToMatch selects the next search match that is older (-1) or newer (1)
+// than the selected message.
+func (m *model) jumpToMatch(direction int) {
+	if !m.search.Active() {
+		return
+	}
+
+	history := m.currentTab().history()
+	index := indexOf(history, m.currentTab().selected)
+	if index < 0 {
+		index = len(history)
+	}
+	for {
+		for i := index + direction; i >= 0 && i < len(history); i += direction {
+			if m.search.Matches(history[i]) && m.visible(history[i]) {
+				m.setSelection(history[i])
+				m.updateNotice()
+				return
 			}
 		}
+
+		// Keep looking further back in the history on disk.
+		if direction > 0 || !m.canLoadOlder() {
+			break
+		}
+		searched := len(history)
+		m.loadOlder()
+		history = m.currentTab().history()
+		index = len(history) - searched
+	}
+
+	m.updateNotice()
+	if direction < 0 {
+		m.footer.SetStatus("No older matches for " + m.search.query)
+	} else {
+		m.footer.SetStatus("No newer matches for " + m.search.query)
+	}
+}
+
+// updateNotice shows the state of the search and the scroll lock in the footer.
+func (m *model) updateNotice() {
+	var notices []string
+	if m.newMessages > 0 {
+		notices = append(notices, m.newMessagesNotice())
+	}
+	if m.search.Active() {
+		notices = append(notices, m.searchNotice())
 	}
+	m.footer.SetNotice(strings.Join(notices, " • "))
 }
 
-func closeTwitchClient(twitchClient *twitch.Client) tea.Cmd {
+func (m model) searchNotice() string {
+	history := m.currentTab().history()
+	selected := m.currentTab().selected
+	total, current := 0, 0
+	for _, msg := range history {
+		if m.search.Matches(msg) && m.visible(msg) {
+			total++
+			if msg == selected {
+				current = total
+			}
+		}
+	}
+
+	position := fmt.Sprintf("%d matches", total)
+	if current > 0 {
+		position = fmt.Sprintf("match %d of %d", current, total)
+	}
+	return fmt.Sprintf("Search: %s (%s) • n/N: Older/Newer match • esc: Clear", m.search.query, position)
+}
+
+// olderEnd is the end of the lines in the history store that have not been
+// loaded yet.
+func (m model) olderEnd() int {
+	t := m.currentTab()
+	if m.historyStore == nil {
+		return 0
+	}
+	if t.older == nil {
+		return m.historyStore.Len(t.channelName)
+	}
+	return t.olderFrom
+}
+
+func (m model) canLoadOlder() bool {
+	return m.olderEnd() > 0
+}
+
+// loadOlder loads a page of the messages evicted from the current tab back
+// from the history store. It reports false if there was nothing to load.
+func (m *model) loadOlder() bool {
+	t := m.currentTab()
+	if m.historyStore == nil {
+		m.footer.SetStatus("Older messages are not kept, use --history-disk to keep them")
+		return false
+	}
+	if !m.canLoadOlder() {
+		m.footer.SetStatus("There are no older messages")
+		return false
+	}
+	end := m.olderEnd()
+	start := max(end-olderPageSize, 0)
+
+	lines, err := m.historyStore.Read(t.channelName, start, end)
+	if err != nil {
+		m.footer.SetStatus(err.Error())
+		return false
+	}
+	page := make([]message.Message, 0, len(lines))
+	for _, line := range lines {
+		if msg := message.ParseRaw(line); msg != nil {
+			page = append(page, msg)
+		}
+	}
+
+	top, _ := m.topAnchor()
+	t.older = append(page, t.older...)
+	t.olderFrom = start
+	// What was loaded on purpose stays, on top of a history worth of messages
+	// evicted while looking at it.
+	t.olderLimit = len(t.older) + m.historySize
+	for _, msg := range t.history() {
+		if moderation, ok := msg.(message.Moderation); ok {
+			applyModeration(t, moderation)
+		}
+	}
+	m.refreshViewport()
+	m.restoreAnchor(top)
+	return true
+}
+
+func (m *model) sendMessage(text string) {
+	msg, err := m.client.Say(m.currentTab().channelName, text)
+	if errors.Is(err, twitch.ErrEmptyMessage) {
+		return
+	}
+	if err != nil {
+		m.footer.SetStatus(err.Error())
+		return
+	}
+	m.addMessage(msg)
+}
+
+type emotesLoadedMsg struct{}
+
+// emoteLoads turns the loads of emotes into at most one emotesLoadedMsg per
+// frame, as every message drops the render cache and a page full of new
+// emotes loads them one by one.
+type emoteLoads struct {
+	pending atomic.Bool
+	send    func(tea.Msg)
+}
+
+func (l *emoteLoads) loaded() {
+	if !l.pending.CompareAndSwap(false, true) {
+		return
+	}
+	time.AfterFunc(frameDuration, func() {
+		// Cleared before sending, so that a load the message comes too
+		// early for sends the next one.
+		l.pending.Store(false)
+		l.send(emotesLoadedMsg{})
+	})
+}
+
+// messageBatchMsg is every message that arrived within a frame.
+type messageBatchMsg []message.Message
+
+func (m model) receiveMessages() tea.Cmd {
+	return func() tea.Msg {
+		batch, ok := m.messageQueue.PopBatch(frameDuration)
+		if !ok {
+			return nil
+		}
+		return messageBatchMsg(batch)
+	}
+}
+
+func closeClient(client chatClient) tea.Cmd {
 	return func() tea.Msg {
-		if err := twitchClient.Disconnect(); err != nil {
+		if err := client.Disconnect(); err != nil {
 			time.Sleep(100 * time.Millisecond)
-			return closeTwitchClient(twitchClient)()
+			return closeClient(client)()
 		}
 
 		return tea.QuitMsg{}
@@ -52,47 +701,139 @@ func closeTwitchClient(twitchClient *twitch.Client) tea.Cmd {
 }
 
 func (m model) Init() tea.Cmd {
-	return m.receiveMessage()
+	return m.receiveMessages()
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(tea.KeyPressMsg); ok {
+		switch {
+		case m.prompt.Active():
+			var promptCmd tea.Cmd
+			m.prompt, promptCmd = m.prompt.Update(msg)
+			return m, promptCmd
+		case m.composer.Focused():
+			var composerCmd tea.Cmd
+			m.composer, composerCmd = m.composer.Update(msg)
+			return m, composerCmd
+		}
+	}
+
+	// Before handling the message, so that keys can set a new status.
+	var footerCmd tea.Cmd
+	m.footer, footerCmd = m.footer.Update(msg)
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		k := msg.String()
 		switch k {
 		case "ctrl+c", "q":
-			if err := m.twitchClient.Disconnect(); err != nil {
+			if err := m.client.Disconnect(); err != nil {
 				m.shuttingDown = true
 			}
-			return m, closeTwitchClient(m.twitchClient)
+			return m, closeClient(m.client)
 		case "t":
-			m.renderTime = !m.renderTime
-			m.viewport.SetContent(m.renderChatHistory())
+			m.renderOptions.Time = !m.renderOptions.Time
+			m.refreshViewport()
+		case "d":
+			m.renderOptions.RevealDeleted = !m.renderOptions.RevealDeleted
+			m.refreshViewport()
+		case "tab":
+			m.switchTab(m.activeTab + 1)
+		case "shift+tab":
+			m.switchTab(m.activeTab - 1)
+		case "+":
+			return m, m.prompt.Open(promptJoin, "Join channel: #", "")
+		case "-":
+			m.partChannel()
+		case "i", "enter":
+			return m, m.composer.Focus(m.currentTab().channelName)
+		case "[":
+			m.moveSelection(-1)
+		case "]":
+			m.moveSelection(1)
+		case "esc":
+			m.clearSearch()
+		case "end":
+			m.setSelection(nil)
+		case "o":
+			m.loadOlder()
+		case "p":
+			m.jumpToParent()
+		case "/":
+			return m, m.prompt.Open(promptSearch, "Search: ", "")
+		case "f":
+			return m, m.prompt.Open(promptFilter, "Filter: ", m.filter.String())
+		case "n":
+			m.jumpToMatch(-1)
+		case "N":
+			m.jumpToMatch(1)
+		case "M":
+			m.toggleMentions()
+		case "I":
+			m.ignoreSelected()
+		case ":":
+			return m, m.prompt.Open(promptCommand, ":", "")
+		case "ctrl+r":
+			m.reloadTheme()
+		case "space":
+			if s, ok := m.client.(stepper); ok {
+				s.Step()
+			}
+		}
+	case composerSubmitMsg:
+		m.sendMessage(msg.text)
+	case promptSubmitMsg:
+		switch msg.kind {
+		case promptJoin:
+			m.joinChannel(msg.value)
+		case promptSearch:
+			m.startSearch(msg.value)
+		case promptFilter:
+			m.setFilter(msg.value)
+		case promptCommand:
+			m.runCommand(msg.value)
 		}
 	case tea.QuitMsg:
 		return m, tea.Quit
 	case tea.WindowSizeMsg:
-		if !m.ready {
-			m.viewport.SetContent(m.renderChatHistory())
-			m.ready = true
+		m.ready = true
+		m.width = msg.Width
+		m.height = msg.Height
+		m.updateLayout()
+		m.refreshViewport()
+		if !m.scrollToSelection() {
+			m.viewport.GotoBottom()
 		}
-		m.viewport.SetWidth(msg.Width)
-		// TODO: We need extra space for the header and footer, but we should probably do this dynamically instead of hardcoding it.
-		m.viewport.SetHeight(msg.Height - 2)
-		m.viewport.GotoBottom()
-	case message.Message:
-		m.messages.Add(msg)
-		m.viewport.SetContent(m.renderChatHistory())
-		m.viewport.GotoBottom()
-		return m, m.receiveMessage()
+	case messageBatchMsg:
+		notifyCmd := m.addMessages(msg)
+		m.footer.SetDropped(m.messageQueue.Dropped())
+		m.footer.SetHidden(m.hidden)
+		return m, tea.Batch(m.receiveMessages(), notifyCmd)
+	case tea.FocusMsg:
+		m.focused = true
+	case tea.BlurMsg:
+		m.focused = false
+	case connectionStatusMsg:
+		m.setConnectionStatus(twitch.Status(msg))
+	case emotesLoadedMsg:
+		// Emotes that were shown as text can be shown as images now.
+		m.renderGeneration++
+		m.refreshViewport()
 	}
 
 	var viewportCmd tea.Cmd
 	m.viewport, viewportCmd = m.viewport.Update(msg)
+	if (m.newMessages > 0 || len(m.currentTab().pending) > 0) && !m.paused() {
+		m.resume()
+	}
 	var headerCmd tea.Cmd
 	m.header, headerCmd = m.header.Update(msg)
+	var promptCmd tea.Cmd
+	m.prompt, promptCmd = m.prompt.Update(msg)
+	var composerCmd tea.Cmd
+	m.composer, composerCmd = m.composer.Update(msg)
 
-	return m, tea.Batch(viewportCmd, headerCmd)
+	return m, tea.Batch(viewportCmd, headerCmd, footerCmd, promptCmd, composerCmd)
 }
 
 func (m model) View() string {
@@ -102,69 +843,352 @@ func (m model) View() string {
 	if m.shuttingDown {
 		return "Shutting down..."
 	}
-	return lipgloss.JoinVertical(
-		lipgloss.Left,
-		m.header.View(),
-		m.viewport.View(),
-		m.footer.View(),
-	)
+	chat := m.viewport.View()
+	if m.mentions.open {
+		chat = lipgloss.JoinHorizontal(lipgloss.Top, chat, m.mentions.View())
+	}
+	views := []string{m.header.View(), chat}
+	if m.composer.enabled {
+		views = append(views, m.composer.View())
+	}
+	views = append(views, m.footerView())
+
+	return lipgloss.JoinVertical(lipgloss.Left, views...)
+}
+
+func (m model) footerView() string {
+	if m.prompt.Active() {
+		return m.prompt.View()
+	}
+	return m.footer.View()
+}
+
+func (m *model) refreshViewport() {
+	var content string
+	content, m.lineOffsets = m.renderChatHistory()
+	m.viewport.SetContent(content)
 }
 
-func (m model) renderChatHistory() string {
-	history := m.messages.Get()
+// renderChatHistory renders the current tab wrapped to the width of the
+// viewport, along with the line each message starts on.
+func (m model) renderChatHistory() (string, []int) {
+	t := m.currentTab()
+	history := t.history()
 	if len(history) == 0 {
-		return "*Crickets*"
+		return "*Crickets*", nil
 	}
 
 	regularMesasgeStyle := lipgloss.NewStyle()
-	userNoticeStyle := lipgloss.NewStyle().Background(lipgloss.Color("#1f1f23"))
+	userNoticeStyle := lipgloss.NewStyle().Background(lipgloss.Color(m.theme.Notice))
+	selectedStyle := lipgloss.NewStyle().Background(lipgloss.Color(m.theme.Selected))
+	if m.theme.Selected == "" {
+		selectedStyle = lipgloss.NewStyle().Reverse(true)
+	}
+	mentionStyle := lipgloss.NewStyle().Background(lipgloss.Color(m.theme.Mention))
+	if m.theme.Mention == "" {
+		mentionStyle = lipgloss.NewStyle().Bold(true)
+	}
+	width := m.viewport.Width() - m.viewport.Style.GetHorizontalFrameSize()
 
 	var builder strings.Builder
+	lineOffsets := make([]int, len(history))
+	line := 0
+	// Only the messages still in history are kept in the cache.
+	cache := make(map[message.Message]renderedMessage, len(history))
+	defer func() { t.rendered = cache }()
 	for i, msg := range history {
-		if i > 0 {
+		if cached, ok := t.rendered[msg]; ok {
+			cache[msg] = cached
+		}
+		if !m.visible(msg) {
+			lineOffsets[i] = -1
+			continue
+		}
+		if line > 0 {
 			builder.WriteString("\n")
 		}
 
-		style := regularMesasgeStyle
-		if _, ok := msg.(message.UserNotice); ok {
-			style = userNoticeStyle
+		key := renderKey{
+			width:      width,
+			options:    m.renderOptions,
+			selected:   msg == t.selected,
+			generation: m.renderGeneration,
+		}
+		rendered, ok := cache[msg]
+		if !ok || rendered.key != key {
+			style := regularMesasgeStyle
+			if _, ok := msg.(message.UserNotice); ok {
+				style = userNoticeStyle
+			}
+			if m.highlighter.Matches(msg) {
+				style = mentionStyle
+			}
+			if key.selected {
+				style = selectedStyle
+			}
+			rendered = renderMessage(msg, key, style)
+			cache[msg] = rendered
 		}
 
-		builder.WriteString(msg.Render(m.renderTime, style))
+		lineOffsets[i] = line
+		line += rendered.lines
+		builder.WriteString(rendered.text)
+	}
+	if line == 0 {
+		return "*Nothing matches the filter*", nil
+	}
+	return builder.String(), lineOffsets
+}
+
+type renderKey struct {
+	width      int
+	options    message.RenderOptions
+	selected   bool
+	generation int
+}
+
+// renderedMessage is a message rendered and wrapped for the chat, along with
+// what it was rendered for.
+type renderedMessage struct {
+	key   renderKey
+	text  string
+	lines int
+}
+
+func renderMessage(msg message.Message, key renderKey, style lipgloss.Style) renderedMessage {
+	text := msg.Render(key.options, style)
+	if key.width > 0 {
+		text = ansi.Wrap(text, key.width, "")
 	}
-	return builder.String()
+	return renderedMessage{key: key, text: text, lines: strings.Count(text, "\n") + 1}
 }
 
-func newModel(channelName string, messageChan <-chan message.Message, twitchIrcClient *twitch.Client) model {
+type modelOptions struct {
+	title        string
+	theme        theme.Theme
+	themeName    string
+	filter       *filter.Filter
+	ignores      *ignore.List
+	highlighter  highlighter
+	notification notification
+	historySize  int
+	historyStore *history.Store
+	chatLogger   *chatlog.Logger
+}
+
+func newModel(channelNames []string, messageQueue *queue.Queue[message.Message], client chatClient, options modelOptions) model {
+	s, canStep := client.(stepper)
+	canStep = canStep && s.Stepping()
 	m := model{
-		channelName:  channelName,
-		messageChan:  messageChan,
+		filter:       options.filter,
+		ignores:      options.ignores,
+		highlighter:  options.highlighter,
+		mentions:     newMentions(options.theme),
+		notification: options.notification,
+		focused:      true,
+		historySize:  options.historySize,
+		historyStore: options.historyStore,
+		chatLogger:   options.chatLogger,
+		messageQueue: messageQueue,
 		viewport:     viewport.New(),
-		twitchClient: twitchIrcClient,
-		footer:       newFooter(),
-		header:       newHeader(fmt.Sprintf("LurkMode - #%s", channelName)),
-		messages:     ringbuffer.NewBuffer[message.Message](historySize),
+		client:       client,
+		prompt:       newPrompt(),
+		composer:     newComposer(client.Authenticated()),
+		footer:       newFooter(client.Authenticated(), canStep, options.theme),
+		header:       newHeader(options.title, options.theme),
+		theme:        options.theme,
+		themeName:    options.themeName,
+	}
+	if _, ok := client.(statusReporter); ok {
+		m.joined = map[string]bool{}
+	}
+	for _, channelName := range channelNames {
+		if _, t := m.findTab(strings.ToLower(channelName)); t == nil {
+			m.tabs = append(m.tabs, m.newTab(channelName))
+		}
 	}
+	m.updateHeader()
+	m.header.SetFilter(options.filter.String())
+
+	m.viewport.Style = viewportStyle(options.theme)
+
+	return m
+}
 
-	m.viewport.Style = lipgloss.NewStyle().
+func viewportStyle(t theme.Theme) lipgloss.Style {
+	return lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#6441a5")).
+		BorderForeground(lipgloss.Color(t.Accent)).
 		Padding(0, 1)
-	m.viewport.SoftWrap = true
+}
 
-	return m
+// reloadTheme reads the theme again, so that changes to a theme file show
+// without restarting.
+func (m *model) reloadTheme() {
+	t, err := theme.Load(m.themeName)
+	if err != nil {
+		m.footer.SetStatus(err.Error())
+		return
+	}
+
+	m.theme = t
+	message.SetTheme(t)
+	emotes.SetTextColor(t.Emote)
+	m.header.SetTheme(t)
+	m.footer.SetTheme(t)
+	m.mentions.SetTheme(t)
+	m.viewport.Style = viewportStyle(t)
+	m.renderGeneration++
+	m.refreshViewport()
+	m.footer.SetStatus("Theme reloaded")
 }
 
-func Run(channelName string) error {
-	tea.LogToFile("debug.log", "")
+// environment is what run uses besides the chat client, so that tests can run
+// the app offline.
+type environment struct {
+	// ctx stops streaming once done, as interrupting does.
+	ctx        context.Context
+	stdout     io.Writer
+	ignorePath string
+	// emoteProviders are asked for third party emotes.
+	emoteProviders []emotes.Provider
+}
+
+func defaultEnvironment() (environment, error) {
+	ignorePath, err := ignore.Path()
+	if err != nil {
+		return environment{}, err
+	}
+	return environment{
+		ctx:            context.Background(),
+		stdout:         os.Stdout,
+		ignorePath:     ignorePath,
+		emoteProviders: emotes.DefaultProviders(),
+	}, nil
+}
 
-	messageChan := make(chan message.Message, 100)
-	twitchIrcClient := twitch.NewClient(messageChan, channelName)
+func Run(cfg config.Config, output Output, channelNames ...string) error {
+	env, err := defaultEnvironment()
+	if err != nil {
+		return err
+	}
+	return runTwitch(env, cfg, output, channelNames)
+}
+
+// runTwitch connects to the IRC server of the config and runs the app.
+func runTwitch(env environment, cfg config.Config, output Output, channelNames []string) error {
+	if len(channelNames) == 0 {
+		return errors.New("at least one channel is required")
+	}
+
+	messageQueue := queue.New[message.Message](messageQueueSize)
+	client, err := twitch.NewClient(messageQueue, twitch.Options{
+		Username:   cfg.Username,
+		OAuthToken: cfg.OAuthToken,
+		Address:    cfg.Server,
+		NoTLS:      cfg.NoTLS,
+		CAFile:     cfg.CAFile,
+	}, channelNames...)
+	if err != nil {
+		return err
+	}
+	return run(env, cfg, output, channelNames, messageQueue, client, "LurkMode")
+}
 
-	program := tea.NewProgram(newModel(channelName, messageChan, twitchIrcClient), tea.WithAltScreen(), tea.WithMouseCellMotion())
+// Replay shows a recording instead of connecting to Twitch. Without channel
+// names, every channel of the recording gets a tab.
+func Replay(cfg config.Config, output Output, path string, replayOptions replay.Options, channelNames ...string) error {
+	messages, err := replay.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(channelNames) == 0 {
+		channelNames = replay.Channels(messages)
+	}
+
+	// There is nothing left to show once the recording is written out.
+	replayOptions.Finish = output != OutputTUI
+
+	env, err := defaultEnvironment()
+	if err != nil {
+		return err
+	}
+	messageQueue := queue.New[message.Message](messageQueueSize)
+	player := replay.NewPlayer(messageQueue, messages, replayOptions)
+	return run(env, cfg, output, channelNames, messageQueue, player, "LurkMode — replay of "+filepath.Base(path))
+}
+
+func run(env environment, cfg config.Config, output Output, channelNames []string, messageQueue *queue.Queue[message.Message], client chatClient, title string) error {
+	emoteMode, err := emotes.ParseMode(cfg.Emotes)
+	if err != nil {
+		return err
+	}
+	emotes.SetMode(emoteMode)
+	emotes.SetProviders(env.emoteProviders...)
+
+	t, err := theme.Load(cfg.Theme)
+	if err != nil {
+		return err
+	}
+	message.SetTheme(t)
+	emotes.SetTextColor(t.Emote)
+
+	options := modelOptions{title: title, theme: t, themeName: cfg.Theme, historySize: cfg.History}
+	if options.historySize <= 0 {
+		options.historySize = defaultHistorySize
+	}
+	if cfg.Filter != "" {
+		if options.filter, err = filter.Parse(cfg.Filter); err != nil {
+			return fmt.Errorf("parsing filter: %w", err)
+		}
+	}
+	if options.highlighter, err = newHighlighter(cfg.Username, cfg.Highlights, cfg.HighlightUsers); err != nil {
+		return err
+	}
+	if options.notification, err = parseNotification(cfg.Notify); err != nil {
+		return err
+	}
+	if options.ignores, err = ignore.Load(env.ignorePath); err != nil {
+		return fmt.Errorf("loading ignore list: %w", err)
+	}
+	if cfg.LogDir != "" {
+		format, err := chatlog.ParseFormat(cfg.LogFormat)
+		if err != nil {
+			return err
+		}
+		if options.chatLogger, err = chatlog.New(cfg.LogDir, format); err != nil {
+			return fmt.Errorf("opening chat log: %w", err)
+		}
+		defer options.chatLogger.Close()
+	}
+
+	if output != OutputTUI {
+		return stream(env.ctx, env.stdout, output, channelNames, messageQueue, client, options.filter, options.ignores, options.chatLogger)
+	}
+
+	if cfg.HistoryOnDisk {
+		dir, err := history.DefaultDir()
+		if err != nil {
+			return err
+		}
+		if options.historyStore, err = history.Open(dir); err != nil {
+			return fmt.Errorf("opening history: %w", err)
+		}
+		defer options.historyStore.Close()
+	}
+
+	tea.LogToFile("debug.log", "")
+
+	program := tea.NewProgram(newModel(channelNames, messageQueue, client, options), tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithReportFocus())
+	loads := &emoteLoads{send: program.Send}
+	emotes.SetOnLoad(loads.loaded)
+	go emotes.LoadGlobal()
+	if reporter, ok := client.(statusReporter); ok {
+		reporter.OnStatus(func(status twitch.Status) { program.Send(connectionStatusMsg(status)) })
+	}
 
 	ircClientReturnChan := make(chan error)
-	go func() { ircClientReturnChan <- twitchIrcClient.Connect() }()
+	go func() { ircClientReturnChan <- client.Connect() }()
 
 	if _, err := program.Run(); err != nil {
 		return err
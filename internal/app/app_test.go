@@ -0,0 +1,184 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/history"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/replay"
+	"github.com/nextthang/lurkmode/internal/twitch"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+func benchmarkMessages(n int) []message.Message {
+	msgs := make([]message.Message, n)
+	for i := range msgs {
+		msgs[i] = message.ParseRaw(fmt.Sprintf(
+			"@badges=subscriber/12;color=#1E90FF;display-name=Viewer%[1]d;id=msg-%[1]d;tmi-sent-ts=%[2]d;user-id=%[1]d;room-id=1 :viewer%[1]d!viewer%[1]d@viewer%[1]d.tmi.twitch.tv PRIVMSG #busy :message number %[1]d with some more text to wrap around",
+			i, 1700000000000+int64(i)*10))
+	}
+	return msgs
+}
+
+func benchmarkModel(b *testing.B) model {
+	b.Helper()
+
+	client := replay.NewPlayer(queue.New[message.Message](1), nil, replay.Options{})
+	m := newModel([]string{"busy"}, nil, client, modelOptions{historySize: defaultHistorySize})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(model)
+	m.addMessages(benchmarkMessages(defaultHistorySize))
+	return m
+}
+
+// BenchmarkAddMessages compares rendering after every message with rendering
+// once per batch, as a busy chat delivers a few dozen messages per frame.
+func BenchmarkAddMessages(b *testing.B) {
+	const batchSize = 50
+	msgs := benchmarkMessages(batchSize)
+
+	b.Run("single", func(b *testing.B) {
+		m := benchmarkModel(b)
+		for b.Loop() {
+			for _, msg := range msgs {
+				m.addMessage(msg)
+			}
+		}
+		b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "msgs/s")
+	})
+	b.Run("batch", func(b *testing.B) {
+		m := benchmarkModel(b)
+		for b.Loop() {
+			m.addMessages(msgs)
+		}
+		b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "msgs/s")
+	})
+}
+
+// BenchmarkRefreshViewport redraws a large history in which nothing changed,
+// like scrolling or selecting does.
+func BenchmarkRefreshViewport(b *testing.B) {
+	const historySize = 5000
+	client := replay.NewPlayer(queue.New[message.Message](1), nil, replay.Options{})
+	m := newModel([]string{"busy"}, nil, client, modelOptions{historySize: historySize})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(model)
+	m.addMessages(benchmarkMessages(historySize))
+
+	for b.Loop() {
+		m.refreshViewport()
+	}
+}
+
+func TestOlderMessagesAreCapped(t *testing.T) {
+	const historySize = 10
+	store, err := history.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	client := replay.NewPlayer(queue.New[message.Message](1), nil, replay.Options{})
+	m := newModel([]string{"busy"}, nil, client, modelOptions{historySize: historySize, historyStore: store})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(model)
+
+	msgs := benchmarkMessages(1000)
+	m.addMessages(msgs[:30])
+	if !m.loadOlder() {
+		t.Fatal("nothing was loaded")
+	}
+	// Staying scrolled up in a busy chat keeps every evicted message.
+	m.addMessages(msgs[30:])
+
+	tab := m.currentTab()
+	if limit := 20 + historySize; len(tab.older) > limit {
+		t.Errorf("kept %d older messages, want at most %d", len(tab.older), limit)
+	}
+	checkContiguous(t, tab.history(), len(msgs))
+
+	// Loading again continues right before the oldest message in memory.
+	if !m.loadOlder() {
+		t.Fatal("nothing was loaded the second time")
+	}
+	checkContiguous(t, m.currentTab().history(), len(msgs))
+}
+
+// checkContiguous checks that history is the benchmark messages up to end,
+// without gaps or duplicates.
+func checkContiguous(t *testing.T, history []message.Message, end int) {
+	t.Helper()
+
+	first := end - len(history)
+	for i, msg := range history {
+		if want := fmt.Sprintf("msg-%d", first+i); msg.(message.Reply).MessageID() != want {
+			t.Fatalf("message %d is %s, want %s", i, msg.(message.Reply).MessageID(), want)
+		}
+	}
+}
+
+func TestHeaderStaysOnOneLine(t *testing.T) {
+	client := replay.NewPlayer(queue.New[message.Message](1), nil, replay.Options{})
+	m := newModel([]string{"first", "second", "third"}, nil, client, modelOptions{title: "lurkmode", historySize: defaultHistorySize})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 40, Height: 20})
+	m = updated.(model)
+
+	// The connection state arrives after the layout was computed.
+	m.setConnectionStatus(twitch.Status{State: twitch.StateReconnecting, Attempt: 3})
+	if got := lipgloss.Height(m.header.View()); got != 1 {
+		t.Errorf("the header is %d lines high, want 1", got)
+	}
+	if got := lipgloss.Height(m.View()); got != 20 {
+		t.Errorf("the view is %d lines high, want 20", got)
+	}
+}
+
+func TestFooterStaysOnOneLine(t *testing.T) {
+	client := replay.NewPlayer(queue.New[message.Message](1), nil, replay.Options{})
+	m := newModel([]string{"lurkmode"}, nil, client, modelOptions{title: "lurkmode", historySize: defaultHistorySize})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 40, Height: 20})
+	m = updated.(model)
+
+	m.footer.SetDropped(12)
+	m.footer.SetHidden(3)
+	footer := m.footer.View()
+	if got := lipgloss.Width(footer); got > 40 {
+		t.Errorf("the footer is %d columns wide, want at most 40", got)
+	}
+	if !strings.HasSuffix(ansi.Strip(footer), "…") {
+		t.Errorf("got %q, want the help cut off with an ellipsis", ansi.Strip(footer))
+	}
+	if got := lipgloss.Height(m.View()); got != 20 {
+		t.Errorf("the view is %d lines high, want 20", got)
+	}
+}
+
+func TestEmoteLoadsAreCoalesced(t *testing.T) {
+	sent := make(chan tea.Msg, 10)
+	loads := &emoteLoads{send: func(msg tea.Msg) { sent <- msg }}
+
+	for range 100 {
+		loads.loaded()
+	}
+	if _, ok := (<-sent).(emotesLoadedMsg); !ok {
+		t.Fatal("got no emotesLoadedMsg")
+	}
+	time.Sleep(2 * frameDuration)
+	if len(sent) != 0 {
+		t.Errorf("got %d more messages for loads within a frame", len(sent))
+	}
+
+	// Loads after the message was sent redraw again.
+	loads.loaded()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Error("a later load sent no message")
+	}
+}
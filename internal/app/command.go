@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/ignore"
+)
+
+// runCommand runs a command typed after pressing :, e.g. "ignore nightbot".
+func (m *model) runCommand(command string) {
+	name, argument, _ := strings.Cut(command, " ")
+	argument = strings.TrimSpace(argument)
+
+	switch name {
+	case "":
+	case "ignore":
+		m.ignore(argument)
+	case "unignore":
+		m.unignore(argument)
+	case "ignores":
+		if ignored := m.ignores.String(); ignored != "" {
+			m.footer.SetStatus("Ignoring " + ignored)
+		} else {
+			m.footer.SetStatus("Nothing is ignored")
+		}
+	default:
+		m.footer.SetStatus(fmt.Sprintf("Unknown command %q, expected ignore, unignore or ignores", name))
+	}
+}
+
+// ignore ignores a user by name, a /pattern/ or, for "bots", the common chat
+// bots.
+func (m *model) ignore(argument string) {
+	var changed bool
+	switch {
+	case argument == "":
+		m.footer.SetStatus("Usage: ignore NAME, ignore /REGEX/ or ignore bots")
+		return
+	case isPattern(argument):
+		var err error
+		if changed, err = m.ignores.IgnorePattern(argument[1 : len(argument)-1]); err != nil {
+			m.footer.SetStatus(err.Error())
+			return
+		}
+	case argument == "bots":
+		for _, bot := range ignore.Bots {
+			changed = m.ignores.IgnoreUser(twitch.User{Name: bot}) || changed
+		}
+	default:
+		changed = m.ignores.IgnoreUser(twitch.User{Name: strings.ToLower(strings.TrimPrefix(argument, "@"))})
+	}
+	if !changed {
+		m.footer.SetStatus("Already ignoring " + argument)
+		return
+	}
+	m.saveIgnores("Ignoring " + argument)
+}
+
+func (m *model) unignore(argument string) {
+	var changed bool
+	switch {
+	case argument == "":
+		m.footer.SetStatus("Usage: unignore NAME or unignore /REGEX/")
+		return
+	case isPattern(argument):
+		changed = m.ignores.UnignorePattern(argument[1 : len(argument)-1])
+	default:
+		changed = m.ignores.UnignoreUser(strings.TrimPrefix(argument, "@"))
+	}
+	if !changed {
+		m.footer.SetStatus("Not ignoring " + argument)
+		return
+	}
+	m.saveIgnores("No longer ignoring " + argument)
+}
+
+// ignoreSelected ignores the sender of the selected message.
+func (m *model) ignoreSelected() {
+	selected := m.currentTab().selected
+	if selected == nil {
+		m.footer.SetStatus("Select a message to ignore its sender")
+		return
+	}
+	sender := selected.Sender()
+	if sender.Name == "" {
+		m.footer.SetStatus("Messages from Twitch can't be ignored")
+		return
+	}
+	if !m.ignores.IgnoreUser(sender) {
+		m.footer.SetStatus("Already ignoring " + sender.Name)
+		return
+	}
+	m.saveIgnores("Ignoring " + sender.Name + " — :unignore " + sender.Name + " to undo")
+}
+
+// saveIgnores writes the changed ignore list and hides what it ignores now.
+func (m *model) saveIgnores(status string) {
+	if err := m.ignores.Save(); err != nil {
+		status = "Failed to save the ignore list: " + err.Error()
+	}
+	m.footer.SetStatus(status)
+
+	if selected := m.currentTab().selected; selected != nil && !m.visible(selected) {
+		m.currentTab().selected = nil
+	}
+	m.refreshViewport()
+	if !m.scrollToSelection() {
+		m.viewport.GotoBottom()
+	}
+	m.updateNotice()
+}
+
+func isPattern(argument string) bool {
+	return len(argument) >= 2 && strings.HasPrefix(argument, "/") && strings.HasSuffix(argument, "/")
+}
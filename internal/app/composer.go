@@ -0,0 +1,71 @@
+package app
+
+import (
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+// Twitch rejects chat messages longer than 500 characters.
+const maxChatMessageLength = 500
+
+type composerSubmitMsg struct {
+	text string
+}
+
+type composer struct {
+	enabled bool
+	input   textinput.Model
+}
+
+func newComposer(enabled bool) composer {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.CharLimit = maxChatMessageLength
+
+	return composer{
+		enabled: enabled,
+		input:   input,
+	}
+}
+
+func (c composer) Focused() bool {
+	return c.input.Focused()
+}
+
+func (c *composer) Focus(channelName string) tea.Cmd {
+	if !c.enabled {
+		return nil
+	}
+	c.input.Placeholder = "Send a message to #" + channelName
+	return c.input.Focus()
+}
+
+func (c *composer) SetWidth(width int) {
+	c.input.SetWidth(width - lipgloss.Width(c.input.Prompt) - 1)
+}
+
+func (c composer) Update(msg tea.Msg) (composer, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyPressMsg); ok && c.input.Focused() {
+		switch keyMsg.String() {
+		case "esc":
+			c.input.Blur()
+			return c, nil
+		case "enter":
+			submit := composerSubmitMsg{text: c.input.Value()}
+			c.input.Reset()
+			return c, func() tea.Msg { return submit }
+		}
+	}
+
+	var cmd tea.Cmd
+	c.input, cmd = c.input.Update(msg)
+	return c, cmd
+}
+
+func (c composer) View() string {
+	if !c.enabled {
+		return ""
+	}
+	return c.input.View()
+}
@@ -1,26 +1,95 @@
 package app
 
 import (
+	"fmt"
+	"strings"
+
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/theme"
 )
 
 type footer struct {
-	content string
-	style   lipgloss.Style
+	content     string
+	status      string
+	notice      string
+	dropped     int
+	hidden      int
+	width       int
+	style       lipgloss.Style
+	statusStyle lipgloss.Style
 }
 
-func newFooter() footer {
-	return footer{
-		content: "  ↑/↓: Navigate • t: Toogle timestamp • q: Quit",
-		style:   lipgloss.NewStyle().Foreground(lipgloss.Color("241")),
+func newFooter(canChat, canStep bool, t theme.Theme) footer {
+	help := []string{"↑/↓: Navigate", "tab: Switch channel", "+/-: Join/Part", "[/]: Select", "p: Parent", "/: Search", "f: Filter", "M: Mentions", "I: Ignore", ":: Command"}
+	if canChat {
+		help = append(help, "i: Chat")
+	}
+	if canStep {
+		help = append(help, "space: Next message")
 	}
+	help = append(help, "t: Toogle timestamp", "d: Toggle deleted", "q: Quit")
+
+	f := footer{content: "  " + strings.Join(help, " • ")}
+	f.SetTheme(t)
+	return f
+}
+
+func (f *footer) SetTheme(t theme.Theme) {
+	f.style = lipgloss.NewStyle().Foreground(lipgloss.Color(t.FooterText))
+	f.statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+}
+
+func (f *footer) SetStatus(status string) {
+	f.status = status
+}
+
+// SetNotice replaces the help text until it is set back to "".
+func (f *footer) SetNotice(notice string) {
+	f.notice = notice
+}
+
+// SetDropped shows how many messages were dropped because we fell behind.
+func (f *footer) SetDropped(dropped int) {
+	f.dropped = dropped
+}
+
+// SetHidden shows how many messages the ignore list kept out of the chat.
+func (f *footer) SetHidden(hidden int) {
+	f.hidden = hidden
 }
 
 func (f footer) Update(msg tea.Msg) (footer, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		f.status = ""
+	case tea.WindowSizeMsg:
+		f.width = msg.Width
+	}
 	return f, nil
 }
 
+// View cuts off what doesn't fit the width of the terminal, as the help
+// alone is wider than most.
 func (f footer) View() string {
-	return f.style.Render(f.content)
+	var view string
+	switch {
+	case f.status != "":
+		view = f.statusStyle.Render("  " + f.status)
+	case f.notice != "":
+		view = f.style.Render("  " + f.notice)
+	default:
+		view = f.style.Render(f.content)
+	}
+	if f.hidden > 0 {
+		view = f.style.Render(fmt.Sprintf("  %d hidden", f.hidden)) + view
+	}
+	if f.dropped > 0 {
+		view = f.statusStyle.Render(fmt.Sprintf("  %d dropped", f.dropped)) + view
+	}
+	if f.width > 0 {
+		view = ansi.Truncate(view, f.width, "…")
+	}
+	return view
 }
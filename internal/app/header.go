@@ -1,24 +1,70 @@
 package app
 
 import (
+	"fmt"
+	"strings"
+
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/theme"
 )
 
+type headerTab struct {
+	name   string
+	unread int
+	// joined is false while we are not receiving messages of the channel.
+	joined bool
+}
+
 type header struct {
-	content string
-	style   lipgloss.Style
-}
-
-func newHeader(content string) header {
-	return header{
-		content: content,
-		style: lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("15")).
-			Background(lipgloss.Color("#6441a5")).
-			Align(lipgloss.Center),
-	}
+	content        string
+	tabs           []headerTab
+	activeTab      int
+	filter         string
+	connection     string
+	mentions       int
+	style          lipgloss.Style
+	tabStyle       lipgloss.Style
+	activeTabStyle lipgloss.Style
+}
+
+func newHeader(content string, t theme.Theme) header {
+	h := header{content: content}
+	h.SetTheme(t)
+	return h
+}
+
+func (h *header) SetTheme(t theme.Theme) {
+	style := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color(t.HeaderText)).
+		Background(lipgloss.Color(t.Accent)).
+		Align(lipgloss.Center).
+		Width(h.style.GetWidth())
+
+	h.style = style
+	h.tabStyle = lipgloss.NewStyle().Inherit(style).Bold(false)
+	h.activeTabStyle = lipgloss.NewStyle().Inherit(style).Reverse(true)
+}
+
+func (h *header) SetTabs(tabs []headerTab, activeTab int) {
+	h.tabs = tabs
+	h.activeTab = activeTab
+}
+
+func (h *header) SetFilter(filter string) {
+	h.filter = filter
+}
+
+func (h *header) SetConnection(connection string) {
+	h.connection = connection
+}
+
+// SetMentions shows how many mentions arrived while the mentions panel was
+// closed.
+func (h *header) SetMentions(mentions int) {
+	h.mentions = mentions
 }
 
 func (h header) Update(msg tea.Msg) (header, tea.Cmd) {
@@ -29,6 +75,49 @@ func (h header) Update(msg tea.Msg) (header, tea.Cmd) {
 	return h, nil
 }
 
+func (h header) renderTabs() string {
+	separator := h.tabStyle.Render(" │ ")
+
+	tabs := make([]string, 0, len(h.tabs))
+	for i, tab := range h.tabs {
+		label := "#" + tab.name
+		if tab.unread > 0 {
+			label += fmt.Sprintf(" (%d)", tab.unread)
+		}
+		if !tab.joined {
+			label += " …"
+		}
+
+		style := h.tabStyle
+		if i == h.activeTab {
+			style = h.activeTabStyle
+		}
+		tabs = append(tabs, style.Render(" "+label+" "))
+	}
+
+	if h.mentions > 0 {
+		tabs = append(tabs, h.tabStyle.Render(fmt.Sprintf(" @ %d ", h.mentions)))
+	}
+	if h.filter != "" {
+		tabs = append(tabs, h.tabStyle.Render(" filter: "+h.filter+" "))
+	}
+	if h.connection != "" {
+		tabs = append(tabs, h.tabStyle.Render(" "+h.connection+" "))
+	}
+
+	return strings.Join(tabs, separator)
+}
+
+// View renders the header on a single line. The layout only measures the
+// header on resize, so tabs, counts and the connection state that don't fit
+// are cut off instead of wrapping.
 func (h header) View() string {
-	return h.style.Render(h.content)
+	content := h.content
+	if len(h.tabs) > 0 {
+		content = h.tabStyle.Bold(true).Render(h.content+" ") + h.renderTabs()
+	}
+	if width := h.style.GetWidth(); width > 0 {
+		content = ansi.Truncate(content, width, "…")
+	}
+	return h.style.Render(content)
 }
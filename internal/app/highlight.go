@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+// highlighter finds the messages that mention us, contain one of the terms we
+// care about or come from one of the users we follow closely.
+type highlighter struct {
+	self     string
+	patterns []*regexp.Regexp
+	users    map[string]bool
+}
+
+// newHighlighter builds a highlighter from terms, which are words or
+// /regexes/, and user names. self is our own name, which is always a mention.
+func newHighlighter(self string, terms, users []string) (highlighter, error) {
+	h := highlighter{self: strings.ToLower(self), users: map[string]bool{}}
+	if h.self != "" {
+		terms = append([]string{h.self}, terms...)
+	}
+	for _, term := range terms {
+		var pattern *regexp.Regexp
+		var err error
+		switch {
+		case term == "":
+			continue
+		case len(term) >= 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/"):
+			pattern, err = regexp.Compile(term[1 : len(term)-1])
+		default:
+			pattern, err = regexp.Compile("(?i)" + wordPattern(term))
+		}
+		if err != nil {
+			return highlighter{}, fmt.Errorf("invalid highlight %q: %w", term, err)
+		}
+		h.patterns = append(h.patterns, pattern)
+	}
+	for _, user := range users {
+		h.users[strings.TrimPrefix(strings.ToLower(user), "@")] = true
+	}
+	return h, nil
+}
+
+// wordPattern matches word as a whole word, so that highlighting "ana" does
+// not highlight "banana".
+func wordPattern(word string) string {
+	pattern := regexp.QuoteMeta(word)
+	runes := []rune(word)
+	if isWordRune(runes[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordRune(runes[len(runes)-1]) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (h highlighter) Matches(msg message.Message) bool {
+	sender := msg.Sender()
+	if sender.Name == "" || sender.Name == h.self {
+		return false
+	}
+	if _, ok := msg.(message.Moderation); ok {
+		return false
+	}
+	if h.users[sender.Name] {
+		return true
+	}
+
+	text := msg.Text()
+	if text == "" {
+		return false
+	}
+	for _, pattern := range h.patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// notification is how we draw attention to a highlight the user might not
+// have seen.
+type notification string
+
+const (
+	notifyNone   notification = ""
+	notifyBell   notification = "bell"
+	notifyOSC9   notification = "osc9"
+	notifyOSC777 notification = "osc777"
+)
+
+func parseNotification(s string) (notification, error) {
+	switch n := notification(s); n {
+	case notifyNone, notifyBell, notifyOSC9, notifyOSC777:
+		return n, nil
+	default:
+		return "", fmt.Errorf("unknown notification %q, expected bell, osc9 or osc777", s)
+	}
+}
+
+// sequence is what to write to the terminal for the notification.
+func (n notification) sequence(title, body string) string {
+	title, body = notificationText(title), notificationText(body)
+	switch n {
+	case notifyBell:
+		return "\a"
+	case notifyOSC9:
+		return ansi.Notify(title + ": " + body)
+	case notifyOSC777:
+		return "\x1b]777;notify;" + strings.ReplaceAll(title, ";", ",") + ";" + body + "\a"
+	default:
+		return ""
+	}
+}
+
+// notificationText removes what could end the escape sequence early.
+func notificationText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
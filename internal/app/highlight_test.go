@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+// chatMessage is a message of login in #lurkmode.
+func chatMessage(login, text string) message.Message {
+	return message.ParseRaw(fmt.Sprintf("@badges=;color=;display-name=%[1]s;id=msg-1;room-id=1;tmi-sent-ts=1700000000000;user-id=42 :%[1]s!%[1]s@%[1]s.tmi.twitch.tv PRIVMSG #lurkmode :%[2]s", login, text))
+}
+
+func TestHighlighterMatches(t *testing.T) {
+	h, err := newHighlighter("LurkBot", []string{"ana", "c++", "/go(lang)?\\b/"}, []string{"@Friend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		login string
+		text  string
+		want  bool
+	}{
+		{"viewer", "hi @lurkbot", true},
+		{"viewer", "LURKBOT are you there", true},
+		{"viewer", "lurkbots everywhere", false},
+		{"viewer", "ana is here", true},
+		{"viewer", "Ana!", true},
+		{"viewer", "banana", false},
+		{"viewer", "anagram", false},
+		{"viewer", "I like c++ a lot", true},
+		{"viewer", "c++11", true},
+		{"viewer", "golang is fun", true},
+		{"viewer", "Golang is fun", false},
+		{"viewer", "gopher", false},
+		{"friend", "anything at all", true},
+		// Our own messages are never highlighted.
+		{"lurkbot", "hi lurkbot", false},
+	}
+	for _, test := range tests {
+		if got := h.Matches(chatMessage(test.login, test.text)); got != test.want {
+			t.Errorf("%s: %q: got %t, want %t", test.login, test.text, got, test.want)
+		}
+	}
+}
+
+func TestHighlighterRejectsInvalidRegex(t *testing.T) {
+	if _, err := newHighlighter("", []string{"/[/"}, nil); err == nil {
+		t.Error("got no error for an invalid regex")
+	}
+}
+
+func TestNotificationSequence(t *testing.T) {
+	tests := []struct {
+		n    notification
+		want string
+	}{
+		{notifyNone, ""},
+		{notifyBell, "\a"},
+		{notifyOSC9, "\x1b]9;#lurk;mode: hi there\a"},
+		{notifyOSC777, "\x1b]777;notify;#lurk,mode;hi there\a"},
+	}
+	for _, test := range tests {
+		// Control characters could end the sequence early, so they go.
+		if got := test.n.sequence("#lurk;mode", "hi\a there\x1b"); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.n, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/viewport"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/theme"
+)
+
+// maxMentions is how many highlighted messages the mentions panel keeps.
+const maxMentions = 100
+
+// mentions is the side panel listing the highlighted messages of every
+// channel, newest at the bottom. Each mention is rendered once when it is
+// added and only wrapped again when the panel changes its size.
+type mentions struct {
+	open bool
+	// texts are the rendered mentions, lines the same wrapped to the panel.
+	texts []string
+	lines []string
+	// unseen counts the mentions that arrived while the panel was closed.
+	unseen     int
+	viewport   viewport.Model
+	titleStyle lipgloss.Style
+}
+
+func newMentions(t theme.Theme) mentions {
+	m := mentions{viewport: viewport.New()}
+	m.SetTheme(t)
+	return m
+}
+
+func (m *mentions) SetTheme(t theme.Theme) {
+	m.viewport.Style = viewportStyle(t)
+	m.titleStyle = lipgloss.NewStyle().Bold(true)
+	m.wrap()
+}
+
+func (m *mentions) SetSize(width, height int) {
+	m.viewport.SetWidth(width)
+	m.viewport.SetHeight(height)
+	m.wrap()
+}
+
+func (m *mentions) Toggle() {
+	m.open = !m.open
+	if m.open {
+		m.unseen = 0
+	}
+}
+
+func (m *mentions) Add(msg message.Message) {
+	text := "#" + msg.ChannelName() + " " + msg.Render(message.RenderOptions{Plain: true}, lipgloss.NewStyle())
+	m.texts = append(m.texts, text)
+	m.lines = append(m.lines, ansi.Wrap(text, m.width(), ""))
+	if len(m.texts) > maxMentions {
+		m.texts = m.texts[len(m.texts)-maxMentions:]
+		m.lines = m.lines[len(m.lines)-maxMentions:]
+	}
+	if !m.open {
+		m.unseen++
+	}
+	m.refresh()
+}
+
+// width is how wide the text in the panel may be.
+func (m mentions) width() int {
+	return m.viewport.Width() - m.viewport.Style.GetHorizontalFrameSize()
+}
+
+// wrap wraps every mention to the width of the panel again.
+func (m *mentions) wrap() {
+	m.lines = m.lines[:0]
+	for _, text := range m.texts {
+		m.lines = append(m.lines, ansi.Wrap(text, m.width(), ""))
+	}
+	m.refresh()
+}
+
+func (m *mentions) refresh() {
+	width := m.width()
+	if width <= 0 {
+		return
+	}
+
+	lines := []string{m.titleStyle.Render(ansi.Truncate(fmt.Sprintf("Mentions (%d)", len(m.texts)), width, "…"))}
+	lines = append(lines, m.lines...)
+	if len(m.texts) == 0 {
+		lines = append(lines, "Nothing yet")
+	}
+
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+	m.viewport.GotoBottom()
+}
+
+func (m mentions) View() string {
+	if m.width() <= 0 {
+		return ""
+	}
+	return m.viewport.View()
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/theme"
+)
+
+func TestMentionsWrapToThePanel(t *testing.T) {
+	m := newMentions(theme.Default())
+	m.SetSize(24, 20)
+	m.Add(chatMessage("viewer", "hey lurkbot, this mention is far too long for a narrow panel"))
+
+	view := m.View()
+	if got := lipgloss.Width(view); got > 24 {
+		t.Errorf("the panel is %d columns wide, want at most 24", got)
+	}
+	if !strings.Contains(ansi.Strip(view), "Mentions (1)") {
+		t.Errorf("got %q, want the count of mentions", ansi.Strip(view))
+	}
+	narrow := strings.Count(m.lines[0], "\n")
+	if narrow == 0 {
+		t.Fatal("the mention was not wrapped")
+	}
+
+	// Resizing wraps the mentions that were added before.
+	m.SetSize(80, 20)
+	if got := lipgloss.Width(m.View()); got <= 24 {
+		t.Errorf("the panel is %d columns wide after resizing, want it wider", got)
+	}
+	if got := strings.Count(m.lines[0], "\n"); got >= narrow {
+		t.Errorf("the mention still wraps %d times, want it wrapped again to fewer lines", got)
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+)
+
+type promptKind uint8
+
+const (
+	promptNone promptKind = iota
+	promptJoin
+	promptSearch
+	promptFilter
+	promptCommand
+)
+
+type promptSubmitMsg struct {
+	kind  promptKind
+	value string
+}
+
+type prompt struct {
+	kind  promptKind
+	input textinput.Model
+}
+
+func newPrompt() prompt {
+	return prompt{
+		input: textinput.New(),
+	}
+}
+
+func (p prompt) Active() bool {
+	return p.kind != promptNone
+}
+
+func (p *prompt) Open(kind promptKind, label, value string) tea.Cmd {
+	p.kind = kind
+	p.input.Prompt = label
+	p.input.Reset()
+	p.input.SetValue(value)
+	p.input.CursorEnd()
+	return p.input.Focus()
+}
+
+func (p *prompt) Close() {
+	p.kind = promptNone
+	p.input.Blur()
+}
+
+func (p prompt) Update(msg tea.Msg) (prompt, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyPressMsg); ok {
+		switch keyMsg.String() {
+		case "esc":
+			p.Close()
+			return p, nil
+		case "enter":
+			submit := promptSubmitMsg{kind: p.kind, value: strings.TrimSpace(p.input.Value())}
+			p.Close()
+			return p, func() tea.Msg { return submit }
+		}
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return p, cmd
+}
+
+func (p prompt) View() string {
+	return p.input.View()
+}
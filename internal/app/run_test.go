@@ -0,0 +1,181 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nextthang/lurkmode/internal/config"
+	"github.com/nextthang/lurkmode/internal/emotes"
+	"github.com/nextthang/lurkmode/internal/emotes/emotestest"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/replay"
+	"github.com/nextthang/lurkmode/internal/twitch/twitchtest"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+const timeout = 5 * time.Second
+
+// lineWriter collects what is streamed and hands out the lines as they are
+// written.
+type lineWriter struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	lines  chan string
+}
+
+func newLineWriter() *lineWriter {
+	return &lineWriter{lines: make(chan string, 100)}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buffer.Write(p)
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// Keep the unfinished line for the next write.
+			w.buffer.Reset()
+			w.buffer.WriteString(line)
+			return len(p), nil
+		}
+		w.lines <- strings.TrimSuffix(line, "\n")
+	}
+}
+
+func (w *lineWriter) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case line := <-w.lines:
+		return line
+	case <-time.After(timeout):
+		t.Fatal("no line was written in time")
+		return ""
+	}
+}
+
+// testEnvironment runs the app against the emote server, with an ignore list
+// in a temporary directory.
+func testEnvironment(t *testing.T, stdout io.Writer, ignoreList string) (environment, *emotestest.Server) {
+	t.Helper()
+
+	emoteServer := emotestest.NewServer()
+	t.Cleanup(emoteServer.Close)
+	t.Cleanup(func() { emotes.SetProviders(emotes.DefaultProviders()...) })
+
+	ignorePath := filepath.Join(t.TempDir(), "ignore.json")
+	if ignoreList != "" {
+		if err := os.WriteFile(ignorePath, []byte(ignoreList), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return environment{
+		ctx:            context.Background(),
+		stdout:         stdout,
+		ignorePath:     ignorePath,
+		emoteProviders: emoteServer.Providers(),
+	}, emoteServer
+}
+
+func TestRunStreamsChat(t *testing.T) {
+	server, err := twitchtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	output := newLineWriter()
+	env, emoteServer := testEnvironment(t, output, `{"users": [{"name": "nightbot"}]}`)
+	var cancel context.CancelFunc
+	env.ctx, cancel = context.WithCancel(env.ctx)
+	defer cancel()
+
+	cfg := config.Config{Emotes: "text", Server: server.Addr(), NoTLS: true}
+	done := make(chan error, 1)
+	go func() { done <- runTwitch(env, cfg, OutputPlain, []string{"lurkmode"}) }()
+	if err := server.WaitJoined("lurkmode", timeout); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := server.PrivMsg("lurkmode", twitchtest.User{DisplayName: "Nightbot"}, "!commands"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.PrivMsg("lurkmode", twitchtest.User{DisplayName: "Viewer"}, "hello chat"); err != nil {
+		t.Fatal(err)
+	}
+	if line := output.next(t); !strings.HasSuffix(line, "Viewer: hello chat") {
+		t.Errorf("got %q, want the message of Viewer", line)
+	}
+
+	// Joining the channel asks every provider for its emotes.
+	want := len(env.emoteProviders)
+	deadline := time.Now().Add(timeout)
+	for emoteServer.Requests() < want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := emoteServer.Requests(); got != want {
+		t.Errorf("the emote server got %d requests, want %d", got, want)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run: %v", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("run did not return once the context was done")
+	}
+}
+
+func TestRunStreamsReplayAsJSON(t *testing.T) {
+	messages, err := replay.LoadFile("../replay/testdata/chat.irc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	env, _ := testEnvironment(t, &output, "")
+	messageQueue := queue.New[message.Message](len(messages))
+	player := replay.NewPlayer(messageQueue, messages, replay.Options{Speed: 1000, Finish: true})
+	if err := run(env, config.Config{Emotes: "text"}, OutputJSON, []string{"lurkmode"}, messageQueue, player, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the messages of the channels asked for are written.
+	var want int
+	for _, msg := range messages {
+		if msg.ChannelName() == "lurkmode" {
+			want++
+		}
+	}
+	var got int
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		var record struct {
+			Channel string `json:"channel"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %d: %v", got+1, err)
+		}
+		if record.Channel != "lurkmode" {
+			t.Errorf("line %d is from #%s", got+1, record.Channel)
+		}
+		got++
+	}
+	if got != want {
+		t.Errorf("got %d lines, want %d", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+// paused reports whether new messages should leave the view alone, which is
+// the case while the user scrolled up or is looking at a selected message.
+func (m model) paused() bool {
+	return m.currentTab().selected != nil || !m.viewport.AtBottom()
+}
+
+// anchor is a message on screen and how far into it the view is scrolled, so
+// that the view can be restored after the content above it changed.
+type anchor struct {
+	msg    message.Message
+	offset int
+}
+
+func (m model) topAnchor() (anchor, int) {
+	history := m.currentTab().history()
+	for i := min(len(history), len(m.lineOffsets)) - 1; i >= 0; i-- {
+		if m.lineOffsets[i] >= 0 && m.lineOffsets[i] <= m.viewport.YOffset {
+			return anchor{msg: history[i], offset: m.viewport.YOffset - m.lineOffsets[i]}, i
+		}
+	}
+	return anchor{}, -1
+}
+
+func (m *model) restoreAnchor(a anchor) {
+	index := indexOf(m.currentTab().history(), a.msg)
+	if index < 0 || index >= len(m.lineOffsets) || m.lineOffsets[index] < 0 {
+		return
+	}
+	m.viewport.SetYOffset(m.lineOffsets[index] + a.offset)
+}
+
+// holdBack reports whether a message should be held back instead of added
+// while paused, which is the case once the history is full and adding it
+// would evict the message at the top of the screen. At most a history worth of
+// messages are held back, after which messages on screen get evicted after all.
+func (m *model) holdBack(t *tab, topIndex int) bool {
+	return t.older == nil && t.messages.Full() && topIndex <= 0 && len(t.pending) < m.historySize
+}
+
+func (m *model) flushPending() {
+	t := m.currentTab()
+	for _, msg := range t.pending {
+		t.messages.Add(msg)
+	}
+	t.pending = nil
+	m.newMessages = 0
+}
+
+// resume adds the held back messages and follows the chat again.
+func (m *model) resume() {
+	m.flushPending()
+	m.currentTab().older = nil
+	m.refreshViewport()
+	m.viewport.GotoBottom()
+	m.updateNotice()
+}
+
+func (m model) newMessagesNotice() string {
+	if m.newMessages == 1 {
+		return "1 new message — press End to resume"
+	}
+	return fmt.Sprintf("%d new messages — press End to resume", m.newMessages)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+// search matches messages by who sent them or what they say. A query of
+// /pattern/ is a regular expression and @name only matches the sender.
+type search struct {
+	query   string
+	pattern *regexp.Regexp
+	names   bool
+}
+
+func parseSearch(query string) (search, error) {
+	s := search{query: query}
+
+	var err error
+	switch {
+	case len(query) >= 2 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/"):
+		s.pattern, err = regexp.Compile(query[1 : len(query)-1])
+	case strings.HasPrefix(query, "@") && len(query) > 1:
+		s.names = true
+		s.pattern, err = regexp.Compile("(?i)^" + regexp.QuoteMeta(query[1:]) + "$")
+	default:
+		s.pattern, err = regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+	}
+	if err != nil {
+		return search{}, fmt.Errorf("invalid search: %w", err)
+	}
+	return s, nil
+}
+
+func (s search) Active() bool {
+	return s.pattern != nil
+}
+
+func (s search) Matches(msg message.Message) bool {
+	if !s.Active() {
+		return false
+	}
+
+	sender := msg.Sender()
+	if sender.DisplayName != "" && s.pattern.MatchString(sender.DisplayName) ||
+		sender.Name != "" && s.pattern.MatchString(sender.Name) {
+		return true
+	}
+	return !s.names && s.pattern.MatchString(msg.Text())
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/nextthang/lurkmode/internal/chatlog"
+	"github.com/nextthang/lurkmode/internal/filter"
+	"github.com/nextthang/lurkmode/internal/ignore"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/twitch"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+// Output is how the messages are shown.
+type Output int
+
+const (
+	OutputTUI Output = iota
+	// OutputPlain writes a line of text per message.
+	OutputPlain
+	// OutputJSON writes a JSON object per message, like the jsonl chat logs.
+	OutputJSON
+)
+
+// stream writes the messages to w line by line instead of showing them in the
+// TUI, until the client is done, ctx is done or we are interrupted.
+func stream(ctx context.Context, w io.Writer, output Output, channelNames []string, messageQueue *queue.Queue[message.Message], client chatClient, messageFilter *filter.Filter, ignores *ignore.List, chatLogger *chatlog.Logger) error {
+	channels := make([]string, len(channelNames))
+	for i, channelName := range channelNames {
+		channels[i] = strings.ToLower(channelName)
+	}
+	format := chatlog.FormatText
+	if output == OutputJSON {
+		format = chatlog.FormatJSON
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if reporter, ok := client.(statusReporter); ok {
+		reporter.OnStatus(func(status twitch.Status) {
+			if status.Channel != "" {
+				log.Printf("Joined #%s", status.Channel)
+				return
+			}
+			log.Printf("Connection: %s", status)
+		})
+	}
+
+	clientReturnChan := make(chan error, 1)
+	go func() { clientReturnChan <- client.Connect() }()
+	go func() {
+		<-ctx.Done()
+		client.Disconnect()
+	}()
+	if s, ok := client.(stepper); ok && s.Stepping() {
+		go stepOnEnter(os.Stdin, s)
+	}
+
+	var writeErr error
+	hidden := 0
+	for {
+		msg, ok := messageQueue.Pop()
+		if !ok {
+			break
+		}
+		if writeErr != nil {
+			continue
+		}
+		if chatLogger != nil {
+			chatLogger.Log(msg)
+		}
+		if !slices.Contains(channels, msg.ChannelName()) || !messageFilter.Match(msg) {
+			continue
+		}
+		if ignores.Matches(msg) {
+			hidden++
+			continue
+		}
+
+		line, err := format.Line(msg)
+		if err != nil {
+			log.Printf("Failed to format message: %v", err)
+			continue
+		}
+		if _, writeErr = w.Write(line); writeErr != nil {
+			// Keep draining the messages until the client is gone.
+			stop()
+		}
+	}
+
+	if hidden > 0 {
+		log.Printf("Hid %d messages of ignored users or patterns", hidden)
+	}
+	if dropped := messageQueue.Dropped(); dropped > 0 {
+		log.Printf("Dropped %d messages as writing fell behind", dropped)
+	}
+	if err := <-clientReturnChan; err != nil {
+		return err
+	}
+	return writeErr
+}
+
+// stepOnEnter steps to the next message for every line read from r.
+func stepOnEnter(r io.Reader, s stepper) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s.Step()
+	}
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/nextthang/lurkmode/internal/emotes"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/twitch"
+	"github.com/nextthang/lurkmode/internal/twitch/twitchtest"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+var endKey = tea.KeyPressMsg{Code: tea.KeyEnd}
+
+// typed returns the key presses for typing text.
+func typed(text string) []tea.Msg {
+	var keys []tea.Msg
+	for _, r := range text {
+		keys = append(keys, tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+	return keys
+}
+
+// driver runs the model like the program would, with the chat of #lurkmode
+// coming from a twitchtest server.
+type driver struct {
+	t      *testing.T
+	m      model
+	server *twitchtest.Server
+}
+
+func newDriver(t *testing.T, historySize int) *driver {
+	t.Helper()
+
+	// Joining the channel loads its emotes from the emote server.
+	env, _ := testEnvironment(t, io.Discard, "")
+	emotes.SetProviders(env.emoteProviders...)
+
+	server, err := twitchtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	messageQueue := queue.New[message.Message](100)
+	client, err := twitch.NewClient(messageQueue, twitch.Options{Address: server.Addr(), NoTLS: true}, "lurkmode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	returned := make(chan struct{})
+	go func() {
+		client.Connect()
+		close(returned)
+	}()
+	t.Cleanup(func() {
+		client.Disconnect()
+		<-returned
+	})
+	if err := server.WaitJoined("lurkmode", timeout); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &driver{
+		t:      t,
+		m:      newModel([]string{"lurkmode"}, messageQueue, client, modelOptions{title: "lurkmode", historySize: historySize}),
+		server: server,
+	}
+	d.update(tea.WindowSizeMsg{Width: 60, Height: 12})
+	return d
+}
+
+// update hands the messages to the model and returns the command of the last.
+func (d *driver) update(msgs ...tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	for _, msg := range msgs {
+		var updated tea.Model
+		updated, cmd = d.m.Update(msg)
+		d.m = updated.(model)
+	}
+	return cmd
+}
+
+// submit presses enter in the open prompt and hands what it submits to the
+// model.
+func (d *driver) submit() {
+	cmd := d.update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if cmd == nil {
+		d.t.Fatal("the prompt submitted nothing")
+	}
+	d.update(cmd())
+}
+
+// chat has Viewer say the texts in #lurkmode and hands them to the model in
+// the batches they arrive in.
+func (d *driver) chat(texts ...string) {
+	d.t.Helper()
+
+	for _, text := range texts {
+		if _, err := d.server.PrivMsg("lurkmode", twitchtest.User{DisplayName: "Viewer"}, text); err != nil {
+			d.t.Fatal(err)
+		}
+	}
+	for received := 0; received < len(texts); {
+		batches := make(chan tea.Msg, 1)
+		go func() { batches <- d.m.receiveMessages()() }()
+		select {
+		case batch := <-batches:
+			received += len(batch.(messageBatchMsg))
+			d.update(batch)
+		case <-time.After(timeout):
+			d.t.Fatalf("got %d of %d messages in time", received, len(texts))
+		}
+	}
+}
+
+func (d *driver) view() string {
+	return ansi.Strip(d.m.View())
+}
+
+func numbered(from, to int) []string {
+	var texts []string
+	for i := from; i <= to; i++ {
+		texts = append(texts, fmt.Sprintf("message %d", i))
+	}
+	return texts
+}
+
+func TestResumingShowsHeldBackMessages(t *testing.T) {
+	d := newDriver(t, 5)
+	d.chat(numbered(1, 5)...)
+
+	// Selecting a message pauses the chat, and with a full history the new
+	// messages are held back to keep the screen as it is.
+	d.update(typed("[")...)
+	d.chat(numbered(6, 8)...)
+	view := d.view()
+	if !strings.Contains(view, "Viewer: message 1") || strings.Contains(view, "message 6") {
+		t.Errorf("the paused view changed:\n%s", view)
+	}
+	if !strings.Contains(view, "3 new messages") {
+		t.Errorf("the footer does not count the new messages:\n%s", view)
+	}
+
+	d.update(endKey)
+	view = d.view()
+	for _, text := range numbered(4, 8) {
+		if !strings.Contains(view, "Viewer: "+text) {
+			t.Errorf("%q is missing after resuming:\n%s", text, view)
+		}
+	}
+	if strings.Contains(view, "new messages") {
+		t.Errorf("the footer still counts new messages:\n%s", view)
+	}
+}
+
+func TestSearchJumpsToMatch(t *testing.T) {
+	d := newDriver(t, defaultHistorySize)
+	d.chat(numbered(1, 2)...)
+	d.chat("the needle")
+	d.chat(numbered(3, 30)...)
+	if view := d.view(); strings.Contains(view, "needle") {
+		t.Fatalf("the match is on screen before searching:\n%s", view)
+	}
+
+	d.update(typed("/needle")...)
+	d.submit()
+	view := d.view()
+	if !strings.Contains(view, "Viewer: the needle") {
+		t.Errorf("the view did not jump to the match:\n%s", view)
+	}
+	if !strings.Contains(view, "match 1 of 1") {
+		t.Errorf("the footer does not show the match:\n%s", view)
+	}
+
+	// Clearing the search follows the chat again.
+	d.update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if view := d.view(); !strings.Contains(view, "Viewer: message 30") {
+		t.Errorf("the view did not go back to the newest message:\n%s", view)
+	}
+}
@@ -0,0 +1,275 @@
+// Package chatlog writes the messages we lurked to per-channel log files, one
+// file per day, in plain text, JSON Lines or raw IRC.
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "jsonl"
+	FormatIRC  Format = "irc"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON, "json":
+		return FormatJSON, nil
+	case FormatIRC:
+		return FormatIRC, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q, expected text, jsonl or irc", s)
+	}
+}
+
+func (f Format) extension() string {
+	switch f {
+	case FormatJSON:
+		return ".jsonl"
+	case FormatIRC:
+		return ".irc"
+	default:
+		return ".log"
+	}
+}
+
+const (
+	queueSize  = 1024
+	dateFormat = time.DateOnly
+)
+
+// channelName matches the Twitch logins we are willing to use as a directory
+// name, so that a channel read from a replay can't point outside the log
+// directory.
+var channelName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+type entry struct {
+	channel string
+	date    string
+	line    []byte
+}
+
+type logFile struct {
+	date string
+	file *os.File
+}
+
+// Logger writes messages from its own goroutine, so that a slow disk does
+// not hold up the chat.
+type Logger struct {
+	dir     string
+	format  Format
+	entries chan entry
+	files   map[string]*logFile
+	done    chan struct{}
+	once    sync.Once
+
+	mutex   sync.Mutex
+	dropped int
+}
+
+func New(dir string, format Format) (*Logger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{
+		dir:     dir,
+		format:  format,
+		entries: make(chan entry, queueSize),
+		files:   map[string]*logFile{},
+		done:    make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+// Log queues a message to be written. Messages are dropped rather than
+// blocking if the writer can't keep up.
+func (l *Logger) Log(msg message.Message) {
+	line, err := l.format.Line(msg)
+	if err != nil {
+		log.Printf("Failed to format message for the chat log: %v", err)
+		return
+	}
+	if line == nil {
+		return
+	}
+
+	e := entry{
+		channel: msg.ChannelName(),
+		date:    msg.SentAt().Local().Format(dateFormat),
+		line:    line,
+	}
+	select {
+	case l.entries <- e:
+	default:
+		l.mutex.Lock()
+		l.dropped++
+		l.mutex.Unlock()
+	}
+}
+
+// Close writes the queued messages and closes the log files.
+func (l *Logger) Close() error {
+	l.once.Do(func() { close(l.entries) })
+	<-l.done
+
+	l.mutex.Lock()
+	if l.dropped > 0 {
+		log.Printf("Dropped %d messages from the chat log as writing fell behind", l.dropped)
+	}
+	l.mutex.Unlock()
+
+	var err error
+	for _, f := range l.files {
+		if closeErr := f.file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+
+	for e := range l.entries {
+		f, err := l.file(e.channel, e.date)
+		if err != nil {
+			log.Printf("Failed to open chat log: %v", err)
+			continue
+		}
+		if _, err := f.Write(e.line); err != nil {
+			log.Printf("Failed to write chat log: %v", err)
+		}
+	}
+}
+
+// file returns the log file of a channel for a day, rotating to a new file
+// when the day changes.
+func (l *Logger) file(channel, date string) (*os.File, error) {
+	if f, ok := l.files[channel]; ok {
+		if f.date == date {
+			return f.file, nil
+		}
+		f.file.Close()
+		delete(l.files, channel)
+	}
+
+	if !channelName.MatchString(channel) {
+		return nil, fmt.Errorf("invalid channel name %q", channel)
+	}
+	dir := filepath.Join(l.dir, channel)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, date+l.format.extension()), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.files[channel] = &logFile{date: date, file: file}
+	return file, nil
+}
+
+// Line formats a message as a line of the format, including the newline. It
+// returns nil for messages that can't be written in the format.
+func (f Format) Line(msg message.Message) ([]byte, error) {
+	switch f {
+	case FormatIRC:
+		if msg.Raw() == "" {
+			return nil, nil
+		}
+		return []byte(strings.TrimRight(msg.Raw(), "\r\n") + "\n"), nil
+	case FormatJSON:
+		line, err := json.Marshal(newRecord(msg))
+		if err != nil {
+			return nil, err
+		}
+		return append(line, '\n'), nil
+	default:
+		return []byte(msg.SentAt().Local().Format(time.DateTime) + " " + plainText(msg) + "\n"), nil
+	}
+}
+
+func plainText(msg message.Message) string {
+	return ansi.Strip(msg.Render(message.RenderOptions{RevealDeleted: true, Plain: true}, lipgloss.NewStyle()))
+}
+
+type user struct {
+	ID          string         `json:"id,omitempty"`
+	Login       string         `json:"login,omitempty"`
+	DisplayName string         `json:"display_name,omitempty"`
+	Color       string         `json:"color,omitempty"`
+	Badges      map[string]int `json:"badges,omitempty"`
+}
+
+type record struct {
+	Time      time.Time         `json:"time"`
+	Channel   string            `json:"channel"`
+	Type      message.Kind      `json:"type"`
+	User      *user             `json:"user,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Rendered  string            `json:"rendered"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	MsgParams map[string]string `json:"msg_params,omitempty"`
+	Raw       string            `json:"raw,omitempty"`
+}
+
+func newRecord(msg message.Message) record {
+	r := record{
+		Time:     msg.SentAt(),
+		Channel:  msg.ChannelName(),
+		Type:     msg.Kind(),
+		Text:     msg.Text(),
+		Rendered: plainText(msg),
+		Raw:      msg.Raw(),
+	}
+	if sender := msg.Sender(); sender.Name != "" {
+		r.User = &user{
+			ID:          sender.ID,
+			Login:       sender.Name,
+			DisplayName: sender.DisplayName,
+			Color:       sender.Color,
+			Badges:      sender.Badges,
+		}
+	}
+	if r.Raw != "" {
+		r.Tags, r.MsgParams = tags(r.Raw)
+	}
+	return r
+}
+
+// tags parses the tags back out of the raw message, which go-twitch-irc does
+// not keep around in a common form.
+func tags(raw string) (map[string]string, map[string]string) {
+	switch v := twitch.ParseMessage(raw).(type) {
+	case *twitch.PrivateMessage:
+		return v.Tags, nil
+	case *twitch.UserNoticeMessage:
+		return v.Tags, v.MsgParams
+	case *twitch.ClearChatMessage:
+		return v.Tags, nil
+	case *twitch.ClearMessage:
+		return v.Tags, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,136 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+const (
+	chatLine   = "@badges=subscriber/12;color=#1E90FF;display-name=Viewer;id=msg-1;room-id=1;tmi-sent-ts=1700000000000;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #lurkmode :hello chat"
+	otherLine  = "@badges=;color=;display-name=Other;id=msg-2;room-id=2;tmi-sent-ts=1700000001000;user-id=43 :other!other@other.tmi.twitch.tv PRIVMSG #otherchannel :hi"
+	nextDay    = "@badges=;color=;display-name=Viewer;id=msg-3;room-id=1;tmi-sent-ts=1700086400000;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #lurkmode :good morning"
+	escapeLine = "@badges=;color=;display-name=Viewer;id=msg-4;room-id=3;tmi-sent-ts=1700000000000;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #../escape :hi"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Format
+	}{
+		{"", FormatText},
+		{"text", FormatText},
+		{"jsonl", FormatJSON},
+		{"json", FormatJSON},
+		{"irc", FormatIRC},
+	}
+	for _, test := range tests {
+		got, err := ParseFormat(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", test.s, got, err, test.want)
+		}
+	}
+
+	if _, err := ParseFormat("csv"); err == nil {
+		t.Error("ParseFormat(\"csv\") returned no error")
+	}
+}
+
+func TestTextLine(t *testing.T) {
+	msg := message.ParseRaw(chatLine)
+	line, err := FormatText.Line(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.UnixMilli(1700000000000).Local().Format(time.DateTime) + " "
+	if got := string(line); !strings.HasPrefix(got, want) || !strings.HasSuffix(got, "Viewer: hello chat\n") {
+		t.Errorf("got %q, want the time and the message of Viewer", got)
+	}
+}
+
+func TestJSONLine(t *testing.T) {
+	msg := message.ParseRaw(chatLine)
+	line, err := FormatJSON.Line(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(line), "}\n") {
+		t.Errorf("got %q, want one JSON object per line", line)
+	}
+
+	var got record
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Channel != "lurkmode" || got.Type != message.KindChat || got.Text != "hello chat" {
+		t.Errorf("got %s %q in #%s, want a chat message in #lurkmode", got.Type, got.Text, got.Channel)
+	}
+	if got.User == nil || got.User.ID != "42" || got.User.Login != "viewer" || got.User.Badges["subscriber"] != 12 {
+		t.Errorf("got user %+v, want viewer with their badges", got.User)
+	}
+	if got.Tags["id"] != "msg-1" || got.Raw != chatLine {
+		t.Errorf("got tags %v and raw %q, want those of the message", got.Tags, got.Raw)
+	}
+	if !got.Time.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("got time %s", got.Time)
+	}
+}
+
+func TestIRCLine(t *testing.T) {
+	line, err := FormatIRC.Line(message.ParseRaw(chatLine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(line); got != chatLine+"\n" {
+		t.Errorf("got %q, want the raw line", got)
+	}
+}
+
+func TestLoggerWritesAFilePerChannelAndDay(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(filepath.Join(dir, "logs"), FormatIRC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{chatLine, otherLine, nextDay, escapeLine} {
+		logger.Log(message.ParseRaw(raw))
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(ms int64) string { return time.UnixMilli(ms).Local().Format(dateFormat) }
+	want := map[string]string{
+		filepath.Join("lurkmode", day(1700000000000)+".irc"):     chatLine + "\n",
+		filepath.Join("otherchannel", day(1700000001000)+".irc"): otherLine + "\n",
+		filepath.Join("lurkmode", day(1700086400000)+".irc"):     nextDay + "\n",
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, "logs", name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("%s: got %q, want %q", name, got, content)
+		}
+	}
+
+	// A channel name that isn't a login is not written at all.
+	if _, err := os.Stat(filepath.Join(dir, "escape")); !os.IsNotExist(err) {
+		t.Errorf("the log escaped its directory: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d channel directories, want 2", len(entries))
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	appName        = "lurkmode"
+	configFileName = "config.json"
+
+	usernameEnv   = "LURKMODE_USERNAME"
+	oauthTokenEnv = "LURKMODE_OAUTH_TOKEN"
+	configPathEnv = "LURKMODE_CONFIG"
+)
+
+type Config struct {
+	Username   string `json:"username,omitempty"`
+	OAuthToken string `json:"oauth_token,omitempty"`
+	// Emotes is one of auto, sixel, kitty or text.
+	Emotes string `json:"emotes,omitempty"`
+	// Filter is the filter expression the chat view starts with.
+	Filter string `json:"filter,omitempty"`
+	// History is the number of messages kept in memory per channel.
+	History int `json:"history,omitempty"`
+	// HistoryOnDisk keeps the messages that do not fit in memory on disk.
+	HistoryOnDisk bool `json:"history_on_disk,omitempty"`
+	// LogDir enables logging chat to files in this directory.
+	LogDir string `json:"log_dir,omitempty"`
+	// LogFormat is one of text, jsonl or irc.
+	LogFormat string `json:"log_format,omitempty"`
+	// Highlights are words or /regexes/ that highlight the messages containing
+	// them, on top of our own name.
+	Highlights []string `json:"highlights,omitempty"`
+	// HighlightUsers highlights every message of these users.
+	HighlightUsers []string `json:"highlight_users,omitempty"`
+	// Notify is one of bell, osc9 or osc777 to be notified of highlights we
+	// might not see.
+	Notify string `json:"notify,omitempty"`
+	// Theme is the name of a built-in theme or a theme file.
+	Theme string `json:"theme,omitempty"`
+	// Server is the host:port of the IRC server to connect to instead of
+	// Twitch's, e.g. a local stand-in.
+	Server string `json:"server,omitempty"`
+	// NoTLS connects to the server without TLS.
+	NoTLS bool `json:"no_tls,omitempty"`
+	// CAFile is a PEM file with the certificates to trust for the server.
+	CAFile string `json:"ca_file,omitempty"`
+}
+
+func (c Config) Authenticated() bool {
+	return c.Username != "" && c.OAuthToken != ""
+}
+
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, appName), nil
+}
+
+func Path() (string, error) {
+	if path := os.Getenv(configPathEnv); path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFileName), nil
+}
+
+// Load reads the config file if there is one and applies the environment
+// variable overrides on top of it. A missing config file is not an error.
+func Load() (Config, error) {
+	var config Config
+
+	path, err := Path()
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return config, err
+	default:
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+
+	if username := os.Getenv(usernameEnv); username != "" {
+		config.Username = username
+	}
+	if token := os.Getenv(oauthTokenEnv); token != "" {
+		config.OAuthToken = token
+	}
+
+	config.Username = strings.ToLower(config.Username)
+	if config.OAuthToken != "" && !strings.HasPrefix(config.OAuthToken, "oauth:") {
+		config.OAuthToken = "oauth:" + config.OAuthToken
+	}
+
+	return config, nil
+}
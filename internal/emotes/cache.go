@@ -0,0 +1,253 @@
+package emotes
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	imageSize           = "1x"
+	defaultExpiry       = 24 * time.Hour
+	httpTimeout         = 10 * time.Second
+	maxImageSize        = 4 << 20
+	memoryCacheCapacity = 512
+)
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+func cacheKey(emote Emote) string {
+	return emote.Provider + "/" + emote.ID + "-" + imageSize
+}
+
+// lruCache is a bounded in-memory cache of rendered emotes.
+type lruCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value string
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (c *lruCache) Get(key string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+func (c *lruCache) Add(key, value string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// diskCache stores downloaded emote images together with the HTTP caching
+// metadata needed to revalidate them.
+type diskCache struct {
+	dir string
+}
+
+type diskMetadata struct {
+	URL     string    `json:"url"`
+	ETag    string    `json:"etag,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "lurkmode", "emotes")
+}
+
+func (c *diskCache) paths(key string) (string, string) {
+	base := filepath.Join(c.dir, filepath.FromSlash(key))
+	return base + ".img", base + ".json"
+}
+
+func (c *diskCache) Load(key string) ([]byte, diskMetadata, error) {
+	var metadata diskMetadata
+	if c.dir == "" {
+		return nil, metadata, fs.ErrNotExist
+	}
+
+	imagePath, metadataPath := c.paths(key)
+	rawMetadata, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return nil, metadata, err
+	}
+	if err := json.Unmarshal(rawMetadata, &metadata); err != nil {
+		return nil, metadata, err
+	}
+	data, err := os.ReadFile(imagePath)
+	return data, metadata, err
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *diskCache) Store(key string, data []byte, metadata diskMetadata) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	imagePath, metadataPath := c.paths(key)
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
+		return err
+	}
+	rawMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := writeFileAtomic(imagePath, data); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(metadataPath, rawMetadata)
+}
+
+// expiry works out how long a response may be cached from its Cache-Control
+// and Expires headers.
+func expiry(header http.Header, now time.Time) time.Time {
+	for directive := range strings.SplitSeq(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(directive)
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+	return now.Add(defaultExpiry)
+}
+
+var errNotModified = errors.New("not modified")
+
+func download(url, etag string) ([]byte, diskMetadata, error) {
+	metadata := diskMetadata{URL: url, ETag: etag}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, metadata, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, metadata, err
+	}
+	defer resp.Body.Close()
+
+	metadata.Expires = expiry(resp.Header, time.Now())
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, metadata, errNotModified
+	case http.StatusOK:
+	default:
+		return nil, metadata, fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize))
+	if err != nil {
+		return nil, metadata, err
+	}
+	metadata.ETag = resp.Header.Get("ETag")
+	return data, metadata, nil
+}
+
+// fetchImageData returns the image of an emote from the disk cache, going to
+// the network only if the cached copy is missing or expired.
+func fetchImageData(emote Emote) ([]byte, error) {
+	key := cacheKey(emote)
+	cached, metadata, err := disk.Load(key)
+	haveCached := err == nil && metadata.URL == emote.URL
+	if haveCached && time.Now().Before(metadata.Expires) {
+		return cached, nil
+	}
+
+	etag := ""
+	if haveCached {
+		etag = metadata.ETag
+	}
+	data, newMetadata, err := download(emote.URL, etag)
+	switch {
+	case errors.Is(err, errNotModified):
+		if storeErr := disk.Store(key, nil, newMetadata); storeErr != nil {
+			log.Printf("Error caching emote %s: %v", emote.Name, storeErr)
+		}
+		return cached, nil
+	case err != nil && haveCached:
+		// A stale emote is better than no emote.
+		return cached, nil
+	case err != nil:
+		return nil, err
+	}
+
+	// Without the disk cache we only have to download the emote again next
+	// time, which is no reason not to show it now.
+	if err := disk.Store(key, data, newMetadata); err != nil {
+		log.Printf("Error caching emote %s: %v", emote.Name, err)
+	}
+	return data, nil
+}
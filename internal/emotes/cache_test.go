@@ -0,0 +1,35 @@
+package emotes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchImageDataWithoutDiskCache(t *testing.T) {
+	image := []byte("not really a png")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(image)
+	}))
+	defer server.Close()
+
+	// A file where the cache directory should be makes every store fail.
+	blocked := filepath.Join(t.TempDir(), "cache")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := disk
+	defer func() { disk = previous }()
+	SetCacheDir(blocked)
+
+	data, err := fetchImageData(Emote{Provider: "test", ID: "1", Name: "Test", URL: server.URL + "/1"})
+	if err != nil {
+		t.Fatalf("fetchImageData: %v", err)
+	}
+	if !bytes.Equal(data, image) {
+		t.Errorf("got %q, want %q", data, image)
+	}
+}
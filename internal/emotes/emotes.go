@@ -1,64 +1,231 @@
-// Unused right now, but might be useful later
 package emotes
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"image"
-	"net/http"
+	"image/png"
+	"log"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	_ "image/png"
+	_ "image/gif"
 
+	"github.com/charmbracelet/lipgloss/v2"
 	"github.com/gempir/go-twitch-irc/v4"
 	"github.com/nextthang/sixel"
+	"golang.org/x/sync/singleflight"
 )
 
+const ProviderTwitch = "twitch"
+
 type Emote struct {
-	sixelString string
+	Provider string
+	ID       string
+	Name     string
+	URL      string
+}
+
+// Occurrence is an emote used in a message. Start and End are inclusive rune
+// offsets into the message text, the same way Twitch reports them.
+type Occurrence struct {
+	Start int
+	End   int
+	Emote Emote
 }
 
-var emoteCache = map[string]Emote{}
+func FromTwitch(twitchEmotes []*twitch.Emote) []Occurrence {
+	var occurrences []Occurrence
+	for _, twitchEmote := range twitchEmotes {
+		emote := Emote{
+			Provider: ProviderTwitch,
+			ID:       twitchEmote.ID,
+			Name:     twitchEmote.Name,
+			URL:      fmt.Sprintf(twitchEmoteUrl, twitchEmote.ID),
+		}
+		for _, position := range twitchEmote.Positions {
+			occurrences = append(occurrences, Occurrence{
+				Start: position.Start,
+				End:   position.End,
+				Emote: emote,
+			})
+		}
+	}
+
+	slices.SortFunc(occurrences, func(a, b Occurrence) int {
+		return a.Start - b.Start
+	})
+	return occurrences
+}
 
 const twitchEmoteUrl string = "https://static-cdn.jtvnw.net/emoticons/v1/%s/1.0"
 
-func fetchEmoteImage(url string) (image.Image, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// How long to wait before trying to load an emote that failed to load again.
+const failureBackoff = 5 * time.Minute
 
-	img, _, err := image.Decode(resp.Body)
-	if err != nil {
-		return nil, err
+var textEmoteStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#a970ff"))
+
+// SetTextColor changes the colour of emotes shown as text.
+func SetTextColor(color string) {
+	textEmoteStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+}
+
+var (
+	disk   = &diskCache{dir: defaultCacheDir()}
+	memory = newLRUCache(memoryCacheCapacity)
+	loads  singleflight.Group
+
+	failuresMutex sync.Mutex
+	failures      = map[string]time.Time{}
+
+	onLoad atomic.Pointer[func()]
+)
+
+// SetCacheDir changes where emote images are stored on disk. An empty string
+// disables the disk cache.
+func SetCacheDir(dir string) {
+	disk = &diskCache{dir: dir}
+}
+
+// SetOnLoad registers a function that is called whenever an emote or a set
+// of emotes finished loading in the background, so that messages can be
+// rendered again.
+func SetOnLoad(fn func()) {
+	onLoad.Store(&fn)
+}
+
+func notifyLoaded() {
+	if fn := onLoad.Load(); fn != nil {
+		(*fn)()
 	}
+}
 
-	return img, nil
+func encodeSixel(img image.Image) (string, error) {
+	builder := new(strings.Builder)
+	builder.WriteString("\x1b[?8452h")
+	if err := sixel.Encode(builder, img); err != nil {
+		return "", err
+	}
+	// builder.WriteString("\x1b[?8452l")
+	return builder.String(), nil
 }
 
-func EmoteString(twitchEmote *twitch.Emote) string {
-	if emote, ok := emoteCache[twitchEmote.Name]; ok {
-		return emote.sixelString
+// The size of an emote in terminal cells when using the kitty graphics protocol.
+const (
+	kittyColumns = 2
+	kittyRows    = 1
+	kittyChunk   = 4096
+)
+
+func encodeKitty(img image.Image) (string, error) {
+	var pngBuffer bytes.Buffer
+	if err := png.Encode(&pngBuffer, img); err != nil {
+		return "", err
 	}
+	payload := base64.StdEncoding.EncodeToString(pngBuffer.Bytes())
 
-	img, err := fetchEmoteImage(fmt.Sprintf(twitchEmoteUrl, twitchEmote.ID))
+	builder := new(strings.Builder)
+	for i := 0; i < len(payload); i += kittyChunk {
+		end := min(i+kittyChunk, len(payload))
+		more := 0
+		if end < len(payload) {
+			more = 1
+		}
+
+		if i == 0 {
+			// C=1 keeps the cursor in place, we pad with spaces below so
+			// that lipgloss knows how wide the emote actually is.
+			fmt.Fprintf(builder, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", kittyColumns, kittyRows, more, payload[i:end])
+		} else {
+			fmt.Fprintf(builder, "\x1b_Gm=%d;%s\x1b\\", more, payload[i:end])
+		}
+	}
+	builder.WriteString(strings.Repeat(" ", kittyColumns))
+
+	return builder.String(), nil
+}
+
+func renderText(emote Emote, style lipgloss.Style) string {
+	return textEmoteStyle.Inherit(style).Render(":" + emote.Name + ":")
+}
+
+func renderImage(emote Emote) (string, error) {
+	data, err := fetchImageData(emote)
 	if err != nil {
-		fmt.Println("Error fetching emote image:", err)
-		return ""
+		return "", err
 	}
 
-	builder := new(strings.Builder)
-	builder.WriteString("\x1b[?8452h")
-	err = sixel.Encode(builder, img)
+	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
-		fmt.Println("Error encoding emote to sixel:", err)
-		return ""
+		return "", err
+	}
+
+	switch mode {
+	case ModeSixel:
+		return encodeSixel(img)
+	case ModeKitty:
+		return encodeKitty(img)
+	default:
+		return "", fmt.Errorf("mode %s does not render images", mode)
+	}
+}
+
+func failedRecently(key string) bool {
+	failuresMutex.Lock()
+	defer failuresMutex.Unlock()
+
+	failedAt, ok := failures[key]
+	return ok && time.Since(failedAt) < failureBackoff
+}
+
+func recordFailure(key string) {
+	failuresMutex.Lock()
+	defer failuresMutex.Unlock()
+
+	failures[key] = time.Now()
+}
+
+// load renders an emote into the memory cache. Concurrent loads of the same
+// emote are deduplicated.
+func load(emote Emote) {
+	key := cacheKey(emote)
+	if failedRecently(key) {
+		return
+	}
+
+	loads.Do(key, func() (any, error) {
+		if _, ok := memory.Get(key); ok {
+			return nil, nil
+		}
+
+		rendered, err := renderImage(emote)
+		if err != nil {
+			log.Printf("Error rendering emote %s: %v", emote.Name, err)
+			recordFailure(key)
+			return nil, err
+		}
+		memory.Add(key, rendered)
+		notifyLoaded()
+		return nil, nil
+	})
+}
+
+// Render returns the emote in the best form the terminal supports. It never
+// blocks on the network: emotes that are not loaded yet are rendered as text
+// and loaded in the background.
+func Render(emote Emote, style lipgloss.Style) string {
+	if mode == ModeText || emote.URL == "" {
+		return renderText(emote, style)
 	}
-	// builder.WriteString("\x1b[?8452l")
 
-	emoteCache[twitchEmote.Name] = Emote{
-		sixelString: builder.String(),
+	if rendered, ok := memory.Get(cacheKey(emote)); ok {
+		return rendered
 	}
 
-	return emoteCache[twitchEmote.Name].sixelString
+	go load(emote)
+	return renderText(emote, style)
 }
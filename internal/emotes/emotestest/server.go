@@ -0,0 +1,223 @@
+// Package emotestest provides a local stand-in for the BTTV, FFZ and 7TV APIs
+// and their CDNs, so that emote loading can be exercised without the network.
+package emotestest
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/nextthang/lurkmode/internal/emotes"
+)
+
+type Server struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	global   map[string][]emotes.Emote
+	channels map[string]map[string][]emotes.Emote
+	requests int
+}
+
+func NewServer() *Server {
+	s := &Server{
+		global:   map[string][]emotes.Emote{},
+		channels: map[string]map[string][]emotes.Emote{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /bttv/cached/emotes/global", s.handleBTTVGlobal)
+	mux.HandleFunc("GET /bttv/cached/users/twitch/{id}", s.handleBTTVChannel)
+	mux.HandleFunc("GET /ffz/set/global", s.handleFFZGlobal)
+	mux.HandleFunc("GET /ffz/room/id/{id}", s.handleFFZChannel)
+	mux.HandleFunc("GET /7tv/emote-sets/global", s.handleSevenTVGlobal)
+	mux.HandleFunc("GET /7tv/users/twitch/{id}", s.handleSevenTVChannel)
+	mux.HandleFunc("GET /cdn/", s.handleImage)
+
+	s.Server = httptest.NewServer(s.countRequests(mux))
+	return s
+}
+
+// Providers returns providers that talk to this server instead of the real APIs.
+func (s *Server) Providers() []emotes.Provider {
+	return []emotes.Provider{
+		&emotes.BTTV{BaseURL: s.URL + "/bttv", CDNURL: s.URL + "/cdn/bttv"},
+		&emotes.FFZ{BaseURL: s.URL + "/ffz"},
+		&emotes.SevenTV{BaseURL: s.URL + "/7tv", CDNURL: s.URL + "/cdn/7tv"},
+	}
+}
+
+// SetGlobal sets the global emotes of a provider. Only the Provider, ID and
+// Name of the emotes are used.
+func (s *Server) SetGlobal(provider string, providerEmotes ...emotes.Emote) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.global[provider] = providerEmotes
+}
+
+// SetChannel sets the emotes of a channel on a provider. Channels without any
+// emotes are reported as not found, like the real APIs do.
+func (s *Server) SetChannel(provider, channelID string, providerEmotes ...emotes.Emote) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.channels[provider] == nil {
+		s.channels[provider] = map[string][]emotes.Emote{}
+	}
+	s.channels[provider][channelID] = providerEmotes
+}
+
+// Requests returns the number of requests the server has handled so far.
+func (s *Server) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests
+}
+
+func (s *Server) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		s.requests++
+		s.mutex.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) globalEmotes(provider string) []emotes.Emote {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.global[provider]
+}
+
+func (s *Server) channelEmotes(provider, channelID string) ([]emotes.Emote, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channelEmotes, ok := s.channels[provider][channelID]
+	return channelEmotes, ok
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type bttvEmote struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+func toBTTV(providerEmotes []emotes.Emote) []bttvEmote {
+	result := make([]bttvEmote, 0, len(providerEmotes))
+	for _, e := range providerEmotes {
+		result = append(result, bttvEmote{ID: e.ID, Code: e.Name})
+	}
+	return result
+}
+
+func (s *Server) handleBTTVGlobal(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, toBTTV(s.globalEmotes(emotes.ProviderBTTV)))
+}
+
+func (s *Server) handleBTTVChannel(w http.ResponseWriter, r *http.Request) {
+	channelEmotes, ok := s.channelEmotes(emotes.ProviderBTTV, r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"channelEmotes": toBTTV(channelEmotes),
+		"sharedEmotes":  []bttvEmote{},
+	})
+}
+
+type ffzEmoticon struct {
+	ID   int               `json:"id"`
+	Name string            `json:"name"`
+	URLs map[string]string `json:"urls"`
+}
+
+func (s *Server) toFFZSet(providerEmotes []emotes.Emote) map[string]any {
+	emoticons := make([]ffzEmoticon, 0, len(providerEmotes))
+	for _, e := range providerEmotes {
+		id, _ := strconv.Atoi(e.ID)
+		emoticons = append(emoticons, ffzEmoticon{
+			ID:   id,
+			Name: e.Name,
+			URLs: map[string]string{"1": s.URL + "/cdn/ffz/emote/" + e.ID + "/1"},
+		})
+	}
+	return map[string]any{"emoticons": emoticons}
+}
+
+func (s *Server) handleFFZGlobal(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"default_sets": []int{3},
+		"sets": map[string]any{
+			"3": s.toFFZSet(s.globalEmotes(emotes.ProviderFFZ)),
+		},
+	})
+}
+
+func (s *Server) handleFFZChannel(w http.ResponseWriter, r *http.Request) {
+	channelEmotes, ok := s.channelEmotes(emotes.ProviderFFZ, r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"sets": map[string]any{
+			"1": s.toFFZSet(channelEmotes),
+		},
+	})
+}
+
+type sevenTVEmote struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func toSevenTVSet(providerEmotes []emotes.Emote) map[string]any {
+	result := make([]sevenTVEmote, 0, len(providerEmotes))
+	for _, e := range providerEmotes {
+		result = append(result, sevenTVEmote{ID: e.ID, Name: e.Name})
+	}
+	return map[string]any{"emotes": result}
+}
+
+func (s *Server) handleSevenTVGlobal(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, toSevenTVSet(s.globalEmotes(emotes.ProviderSevenTV)))
+}
+
+func (s *Server) handleSevenTVChannel(w http.ResponseWriter, r *http.Request) {
+	channelEmotes, ok := s.channelEmotes(emotes.ProviderSevenTV, r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"emote_set": toSevenTVSet(channelEmotes),
+	})
+}
+
+// handleImage serves a small square for every emote image.
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	img := image.NewRGBA(image.Rect(0, 0, 28, 28))
+	for y := range 28 {
+		for x := range 28 {
+			img.Set(x, y, color.RGBA{R: 0x64, G: 0x41, B: 0xa5, A: 0xff})
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, img)
+}
@@ -0,0 +1,80 @@
+package emotes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Mode uint8
+
+const (
+	ModeText Mode = iota
+	ModeSixel
+	ModeKitty
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeSixel:
+		return "sixel"
+	case ModeKitty:
+		return "kitty"
+	default:
+		return "text"
+	}
+}
+
+var mode = ModeText
+
+func SetMode(m Mode) {
+	mode = m
+}
+
+func CurrentMode() Mode {
+	return mode
+}
+
+// ParseMode parses a mode name as given on the command line. An empty string
+// or "auto" detects the mode from the terminal.
+func ParseMode(name string) (Mode, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return DetectMode(), nil
+	case "text":
+		return ModeText, nil
+	case "sixel":
+		return ModeSixel, nil
+	case "kitty":
+		return ModeKitty, nil
+	default:
+		return ModeText, fmt.Errorf("unknown emote mode %q", name)
+	}
+}
+
+// DetectMode guesses the graphics capabilities of the terminal from the
+// environment. Terminals we know nothing about get text emotes.
+func DetectMode() Mode {
+	term := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "",
+		strings.Contains(term, "kitty"),
+		strings.Contains(term, "ghostty"),
+		termProgram == "ghostty",
+		termProgram == "WezTerm":
+		return ModeKitty
+	case os.Getenv("WT_SESSION") != "",
+		strings.HasPrefix(term, "foot"),
+		strings.HasPrefix(term, "mlterm"),
+		strings.HasPrefix(term, "contour"),
+		strings.HasPrefix(term, "yaft"),
+		strings.Contains(term, "sixel"),
+		termProgram == "iTerm.app",
+		termProgram == "mintty":
+		return ModeSixel
+	default:
+		return ModeText
+	}
+}
@@ -0,0 +1,222 @@
+package emotes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProviderBTTV    = "bttv"
+	ProviderFFZ     = "ffz"
+	ProviderSevenTV = "7tv"
+)
+
+const (
+	defaultBTTVURL       = "https://api.betterttv.net/3"
+	defaultBTTVCDNURL    = "https://cdn.betterttv.net"
+	defaultFFZURL        = "https://api.frankerfacez.com/v1"
+	defaultSevenTVURL    = "https://7tv.io/v3"
+	defaultSevenTVCDNURL = "https://cdn.7tv.app"
+
+	bttvEmoteUrl    = "%s/emote/%s/1x"
+	sevenTVEmoteUrl = "%s/emote/%s/1x.png"
+)
+
+// Provider is a source of emotes that Twitch itself does not know about. The
+// channel ID is the Twitch user ID of the broadcaster.
+type Provider interface {
+	Name() string
+	GlobalEmotes(ctx context.Context) ([]Emote, error)
+	ChannelEmotes(ctx context.Context, channelID string) ([]Emote, error)
+}
+
+func DefaultProviders() []Provider {
+	return []Provider{
+		&BTTV{BaseURL: defaultBTTVURL, CDNURL: defaultBTTVCDNURL},
+		&FFZ{BaseURL: defaultFFZURL},
+		&SevenTV{BaseURL: defaultSevenTVURL, CDNURL: defaultSevenTVCDNURL},
+	}
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Channels without any emotes on a provider are reported as not found.
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type BTTV struct {
+	BaseURL string
+	CDNURL  string
+}
+
+type bttvEmote struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+}
+
+func (p *BTTV) Name() string {
+	return ProviderBTTV
+}
+
+func (p *BTTV) convert(bttvEmotes []bttvEmote) []Emote {
+	result := make([]Emote, 0, len(bttvEmotes))
+	for _, e := range bttvEmotes {
+		result = append(result, Emote{
+			Provider: ProviderBTTV,
+			ID:       e.ID,
+			Name:     e.Code,
+			URL:      fmt.Sprintf(bttvEmoteUrl, p.CDNURL, e.ID),
+		})
+	}
+	return result
+}
+
+func (p *BTTV) GlobalEmotes(ctx context.Context) ([]Emote, error) {
+	var response []bttvEmote
+	if err := getJSON(ctx, p.BaseURL+"/cached/emotes/global", &response); err != nil {
+		return nil, err
+	}
+	return p.convert(response), nil
+}
+
+func (p *BTTV) ChannelEmotes(ctx context.Context, channelID string) ([]Emote, error) {
+	var response struct {
+		ChannelEmotes []bttvEmote `json:"channelEmotes"`
+		SharedEmotes  []bttvEmote `json:"sharedEmotes"`
+	}
+	if err := getJSON(ctx, p.BaseURL+"/cached/users/twitch/"+channelID, &response); err != nil {
+		return nil, err
+	}
+	return p.convert(append(response.ChannelEmotes, response.SharedEmotes...)), nil
+}
+
+type FFZ struct {
+	BaseURL string
+}
+
+type ffzSet struct {
+	Emoticons []struct {
+		ID   int               `json:"id"`
+		Name string            `json:"name"`
+		URLs map[string]string `json:"urls"`
+	} `json:"emoticons"`
+}
+
+func (p *FFZ) Name() string {
+	return ProviderFFZ
+}
+
+func (p *FFZ) convert(sets map[string]ffzSet) []Emote {
+	var result []Emote
+	for _, set := range sets {
+		for _, e := range set.Emoticons {
+			url := e.URLs["1"]
+			if strings.HasPrefix(url, "//") {
+				url = "https:" + url
+			}
+			result = append(result, Emote{
+				Provider: ProviderFFZ,
+				ID:       strconv.Itoa(e.ID),
+				Name:     e.Name,
+				URL:      url,
+			})
+		}
+	}
+	return result
+}
+
+func (p *FFZ) GlobalEmotes(ctx context.Context) ([]Emote, error) {
+	var response struct {
+		DefaultSets []int             `json:"default_sets"`
+		Sets        map[string]ffzSet `json:"sets"`
+	}
+	if err := getJSON(ctx, p.BaseURL+"/set/global", &response); err != nil {
+		return nil, err
+	}
+
+	// Only the default sets are available to everyone.
+	sets := map[string]ffzSet{}
+	for _, id := range response.DefaultSets {
+		key := strconv.Itoa(id)
+		sets[key] = response.Sets[key]
+	}
+	return p.convert(sets), nil
+}
+
+func (p *FFZ) ChannelEmotes(ctx context.Context, channelID string) ([]Emote, error) {
+	var response struct {
+		Sets map[string]ffzSet `json:"sets"`
+	}
+	if err := getJSON(ctx, p.BaseURL+"/room/id/"+channelID, &response); err != nil {
+		return nil, err
+	}
+	return p.convert(response.Sets), nil
+}
+
+type SevenTV struct {
+	BaseURL string
+	CDNURL  string
+}
+
+type sevenTVEmoteSet struct {
+	Emotes []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"emotes"`
+}
+
+func (p *SevenTV) Name() string {
+	return ProviderSevenTV
+}
+
+func (p *SevenTV) convert(set sevenTVEmoteSet) []Emote {
+	result := make([]Emote, 0, len(set.Emotes))
+	for _, e := range set.Emotes {
+		result = append(result, Emote{
+			Provider: ProviderSevenTV,
+			ID:       e.ID,
+			Name:     e.Name,
+			URL:      fmt.Sprintf(sevenTVEmoteUrl, p.CDNURL, e.ID),
+		})
+	}
+	return result
+}
+
+func (p *SevenTV) GlobalEmotes(ctx context.Context) ([]Emote, error) {
+	var response sevenTVEmoteSet
+	if err := getJSON(ctx, p.BaseURL+"/emote-sets/global", &response); err != nil {
+		return nil, err
+	}
+	return p.convert(response), nil
+}
+
+func (p *SevenTV) ChannelEmotes(ctx context.Context, channelID string) ([]Emote, error) {
+	var response struct {
+		EmoteSet sevenTVEmoteSet `json:"emote_set"`
+	}
+	if err := getJSON(ctx, p.BaseURL+"/users/twitch/"+channelID, &response); err != nil {
+		return nil, err
+	}
+	return p.convert(response.EmoteSet), nil
+}
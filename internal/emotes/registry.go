@@ -0,0 +1,215 @@
+package emotes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const loadTimeout = 10 * time.Second
+
+// A channel whose emotes could not be loaded from any provider is tried
+// again after channelRetryDelay, doubling up to maxChannelRetryDelay.
+const (
+	channelRetryDelay    = 30 * time.Second
+	maxChannelRetryDelay = 10 * time.Minute
+)
+
+// Registry keeps track of the third party emotes that are available globally
+// and in the channels we are in.
+type Registry struct {
+	providers []Provider
+
+	mutex    sync.RWMutex
+	global   map[string]Emote
+	channels map[string]map[string]Emote
+	// retrying holds the channels that are waiting to be loaded again.
+	retrying map[string]bool
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	return &Registry{
+		providers: providers,
+		global:    map[string]Emote{},
+		channels:  map[string]map[string]Emote{},
+		retrying:  map[string]bool{},
+	}
+}
+
+var defaultRegistry = NewRegistry(DefaultProviders()...)
+
+func SetProviders(providers ...Provider) {
+	defaultRegistry = NewRegistry(providers...)
+}
+
+// LoadGlobal and LoadChannel call the function set with SetOnLoad once new
+// emotes are known, as messages rendered before show them as plain words.
+// LoadChannel keeps trying in the background while no provider can be reached.
+func LoadGlobal() {
+	if defaultRegistry.LoadGlobal(context.Background()) {
+		notifyLoaded()
+	}
+}
+
+func LoadChannel(channelID string) {
+	defaultRegistry.loadChannel(channelID, 0)
+}
+
+// loadChannel loads the emotes of a channel and, while every provider fails,
+// keeps trying again with backoff. retryDelay is zero unless this is one of
+// those tries.
+func (r *Registry) loadChannel(channelID string, retryDelay time.Duration) {
+	loaded, err := r.LoadChannel(context.Background(), channelID)
+	if loaded {
+		notifyLoaded()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err == nil {
+		delete(r.retrying, channelID)
+		return
+	}
+	if retryDelay == 0 {
+		if r.retrying[channelID] {
+			// The tries that are already going on are enough.
+			return
+		}
+		r.retrying[channelID] = true
+		retryDelay = channelRetryDelay
+	} else {
+		retryDelay = min(2*retryDelay, maxChannelRetryDelay)
+	}
+	time.AfterFunc(retryDelay, func() { r.loadChannel(channelID, retryDelay) })
+}
+
+func Tokenize(channelID, text string, native []Occurrence) []Occurrence {
+	return defaultRegistry.Tokenize(channelID, text, native)
+}
+
+// LoadGlobal fetches the global emotes of every provider. Providers that fail
+// are logged and skipped, so one of them being down does not affect the others.
+// It reports whether any emotes were loaded.
+func (r *Registry) LoadGlobal(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+
+	emotes := map[string]Emote{}
+	for _, provider := range r.providers {
+		providerEmotes, err := provider.GlobalEmotes(ctx)
+		if err != nil {
+			log.Printf("Error loading global %s emotes: %v", provider.Name(), err)
+			continue
+		}
+		for _, emote := range providerEmotes {
+			emotes[emote.Name] = emote
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.global = emotes
+	return len(emotes) > 0
+}
+
+// LoadChannel fetches the emotes of a channel once. Calling it again for the
+// same channel is a no-op, unless every provider failed the last time, which
+// is reported as error. It reports whether any emotes were loaded.
+func (r *Registry) LoadChannel(ctx context.Context, channelID string) (bool, error) {
+	if channelID == "" {
+		return false, nil
+	}
+
+	r.mutex.Lock()
+	if _, ok := r.channels[channelID]; ok {
+		r.mutex.Unlock()
+		return false, nil
+	}
+	// Claim the channel so that concurrent calls don't load it twice.
+	r.channels[channelID] = map[string]Emote{}
+	r.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+
+	emotes := map[string]Emote{}
+	var errs []error
+	for _, provider := range r.providers {
+		providerEmotes, err := provider.ChannelEmotes(ctx, channelID)
+		if err != nil {
+			log.Printf("Error loading %s emotes for channel %s: %v", provider.Name(), channelID, err)
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		for _, emote := range providerEmotes {
+			emotes[emote.Name] = emote
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(errs) > 0 && len(errs) == len(r.providers) {
+		// Most likely the network is down, so give up the claim for the
+		// channel to be loaded again later.
+		delete(r.channels, channelID)
+		return false, errors.Join(errs...)
+	}
+	r.channels[channelID] = emotes
+	return len(emotes) > 0, nil
+}
+
+func (r *Registry) lookup(channelID, word string) (Emote, bool) {
+	if emote, ok := r.channels[channelID][word]; ok {
+		return emote, true
+	}
+	emote, ok := r.global[word]
+	return emote, ok
+}
+
+func overlaps(occurrences []Occurrence, start, end int) bool {
+	for _, occurrence := range occurrences {
+		if occurrence.Start <= end && start <= occurrence.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokenize finds the third party emotes in a message and merges them with
+// the native Twitch emotes, which always take precedence.
+func (r *Registry) Tokenize(channelID, text string, native []Occurrence) []Occurrence {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if len(r.global) == 0 && len(r.channels[channelID]) == 0 {
+		return native
+	}
+
+	occurrences := slices.Clone(native)
+	runes := []rune(text)
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && runes[i] != ' ' {
+			continue
+		}
+		if i > start {
+			if emote, ok := r.lookup(channelID, string(runes[start:i])); ok && !overlaps(native, start, i-1) {
+				occurrences = append(occurrences, Occurrence{
+					Start: start,
+					End:   i - 1,
+					Emote: emote,
+				})
+			}
+		}
+		start = i + 1
+	}
+
+	slices.SortFunc(occurrences, func(a, b Occurrence) int {
+		return a.Start - b.Start
+	})
+	return occurrences
+}
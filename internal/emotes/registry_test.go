@@ -0,0 +1,224 @@
+package emotes_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nextthang/lurkmode/internal/emotes"
+	"github.com/nextthang/lurkmode/internal/emotes/emotestest"
+)
+
+const channelID = "1234"
+
+func newRegistry(t *testing.T) (*emotes.Registry, *emotestest.Server) {
+	t.Helper()
+
+	server := emotestest.NewServer()
+	t.Cleanup(server.Close)
+	return emotes.NewRegistry(server.Providers()...), server
+}
+
+func names(occurrences []emotes.Occurrence) map[string]emotes.Occurrence {
+	result := map[string]emotes.Occurrence{}
+	for _, occurrence := range occurrences {
+		result[occurrence.Emote.Name] = occurrence
+	}
+	return result
+}
+
+func TestRegistryLoadsEveryProvider(t *testing.T) {
+	registry, server := newRegistry(t)
+	server.SetGlobal(emotes.ProviderBTTV, emotes.Emote{ID: "b1", Name: "catJAM"})
+	server.SetGlobal(emotes.ProviderFFZ, emotes.Emote{ID: "1", Name: "LilZ"})
+	server.SetGlobal(emotes.ProviderSevenTV, emotes.Emote{ID: "s1", Name: "EZ"})
+
+	registry.LoadGlobal(context.Background())
+	found := names(registry.Tokenize(channelID, "catJAM LilZ EZ Kappa", nil))
+
+	for name, provider := range map[string]string{
+		"catJAM": emotes.ProviderBTTV,
+		"LilZ":   emotes.ProviderFFZ,
+		"EZ":     emotes.ProviderSevenTV,
+	} {
+		occurrence, ok := found[name]
+		if !ok {
+			t.Errorf("%s was not found", name)
+			continue
+		}
+		if occurrence.Emote.Provider != provider {
+			t.Errorf("%s is from %s, want %s", name, occurrence.Emote.Provider, provider)
+		}
+		if occurrence.Emote.URL == "" {
+			t.Errorf("%s has no URL", name)
+		}
+	}
+	if _, ok := found["Kappa"]; ok {
+		t.Error("Kappa is not a third party emote")
+	}
+}
+
+func TestRegistryChannelEmotesTakePrecedence(t *testing.T) {
+	registry, server := newRegistry(t)
+	server.SetGlobal(emotes.ProviderBTTV, emotes.Emote{ID: "global", Name: "Clap"})
+	server.SetChannel(emotes.ProviderSevenTV, channelID, emotes.Emote{ID: "channel", Name: "Clap"})
+
+	registry.LoadGlobal(context.Background())
+	registry.LoadChannel(context.Background(), channelID)
+
+	tests := []struct {
+		channelID string
+		want      string
+	}{
+		{channelID, "channel"},
+		{"other", "global"},
+	}
+	for _, test := range tests {
+		occurrences := registry.Tokenize(test.channelID, "Clap", nil)
+		if len(occurrences) != 1 {
+			t.Fatalf("channel %s: got %d occurrences, want 1", test.channelID, len(occurrences))
+		}
+		if got := occurrences[0].Emote.ID; got != test.want {
+			t.Errorf("channel %s: got emote %s, want %s", test.channelID, got, test.want)
+		}
+	}
+}
+
+func TestRegistryNativeEmotesWin(t *testing.T) {
+	registry, server := newRegistry(t)
+	server.SetGlobal(emotes.ProviderBTTV,
+		emotes.Emote{ID: "b1", Name: "Kappa"},
+		emotes.Emote{ID: "b2", Name: "catJAM"},
+	)
+	registry.LoadGlobal(context.Background())
+
+	native := []emotes.Occurrence{{
+		Start: 0,
+		End:   4,
+		Emote: emotes.Emote{Provider: emotes.ProviderTwitch, ID: "25", Name: "Kappa"},
+	}}
+	occurrences := registry.Tokenize(channelID, "Kappa catJAM Kappa", native)
+
+	want := []struct {
+		start    int
+		provider string
+	}{
+		{0, emotes.ProviderTwitch},
+		{6, emotes.ProviderBTTV},
+		{13, emotes.ProviderBTTV},
+	}
+	if len(occurrences) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %+v", len(occurrences), len(want), occurrences)
+	}
+	for i, w := range want {
+		if occurrences[i].Start != w.start || occurrences[i].Emote.Provider != w.provider {
+			t.Errorf("occurrence %d: got %s at %d, want %s at %d",
+				i, occurrences[i].Emote.Provider, occurrences[i].Start, w.provider, w.start)
+		}
+	}
+}
+
+func TestChannelWithoutEmotes(t *testing.T) {
+	_, server := newRegistry(t)
+
+	// The server answers 404 for channels it knows nothing about, like the
+	// real APIs do for channels that never set up any emotes.
+	for _, provider := range server.Providers() {
+		channelEmotes, err := provider.ChannelEmotes(context.Background(), "unknown")
+		if err != nil {
+			t.Errorf("%s: %v", provider.Name(), err)
+		}
+		if len(channelEmotes) != 0 {
+			t.Errorf("%s: got %d emotes, want none", provider.Name(), len(channelEmotes))
+		}
+	}
+}
+
+func TestRegistryLoadsChannelOnce(t *testing.T) {
+	registry, server := newRegistry(t)
+	server.SetChannel(emotes.ProviderBTTV, channelID, emotes.Emote{ID: "b1", Name: "catJAM"})
+
+	registry.LoadChannel(context.Background(), channelID)
+	requests := server.Requests()
+	registry.LoadChannel(context.Background(), channelID)
+
+	if got := server.Requests(); got != requests {
+		t.Errorf("loading the channel again made %d more requests", got-requests)
+	}
+}
+
+func TestLoadingEmotesNotifies(t *testing.T) {
+	server := emotestest.NewServer()
+	defer server.Close()
+	server.SetGlobal(emotes.ProviderBTTV, emotes.Emote{ID: "b1", Name: "catJAM"})
+	server.SetChannel(emotes.ProviderFFZ, channelID, emotes.Emote{ID: "1", Name: "LilZ"})
+	emotes.SetProviders(server.Providers()...)
+	defer emotes.SetProviders(emotes.DefaultProviders()...)
+
+	var loads atomic.Int32
+	emotes.SetOnLoad(func() { loads.Add(1) })
+	defer emotes.SetOnLoad(func() {})
+
+	emotes.LoadGlobal()
+	emotes.LoadChannel(channelID)
+	// Neither a channel that was loaded already nor one without any emotes
+	// changes how messages render.
+	emotes.LoadChannel(channelID)
+	emotes.LoadChannel("unknown")
+
+	if got := loads.Load(); got != 2 {
+		t.Errorf("notified %d times, want 2", got)
+	}
+	if occurrences := emotes.Tokenize(channelID, "catJAM LilZ", nil); len(occurrences) != 2 {
+		t.Errorf("got %d emotes, want 2", len(occurrences))
+	}
+}
+
+// flakyProvider fails until it is told to work.
+type flakyProvider struct {
+	emotes.Provider
+	failing atomic.Bool
+}
+
+func (p *flakyProvider) ChannelEmotes(ctx context.Context, channelID string) ([]emotes.Emote, error) {
+	if p.failing.Load() {
+		return nil, errors.New("network is down")
+	}
+	return p.Provider.ChannelEmotes(ctx, channelID)
+}
+
+func TestRegistryLoadsFailedChannelAgain(t *testing.T) {
+	server := emotestest.NewServer()
+	defer server.Close()
+	server.SetChannel(emotes.ProviderBTTV, channelID, emotes.Emote{ID: "b1", Name: "catJAM"})
+
+	var providers []emotes.Provider
+	var flaky []*flakyProvider
+	for _, provider := range server.Providers() {
+		p := &flakyProvider{Provider: provider}
+		p.failing.Store(true)
+		providers = append(providers, p)
+		flaky = append(flaky, p)
+	}
+	registry := emotes.NewRegistry(providers...)
+
+	if loaded, err := registry.LoadChannel(context.Background(), channelID); loaded || err == nil {
+		t.Fatalf("got %v, %v, want an error when every provider fails", loaded, err)
+	}
+
+	// One provider failing is not enough to try again.
+	for _, p := range flaky[1:] {
+		p.failing.Store(false)
+	}
+	if _, err := registry.LoadChannel(context.Background(), channelID); err != nil {
+		t.Fatalf("got %v with only one provider failing", err)
+	}
+	if len(registry.Tokenize(channelID, "catJAM", nil)) != 0 {
+		t.Fatal("got the emote of the failing provider")
+	}
+	flaky[0].failing.Store(false)
+	if loaded, err := registry.LoadChannel(context.Background(), channelID); loaded || err != nil {
+		t.Errorf("got %v, %v, want a loaded channel to stay as it is", loaded, err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package filter implements the expressions used to narrow down the chat
+// view, e.g. "badge:mod -type:notice" or "user:foo text~/https?:/".
+//
+// An expression is a list of terms separated by spaces, all of which have to
+// match. A term prefixed with - has to not match instead. A /REGEX/ may
+// contain spaces, and \/ for a slash. The terms are:
+//
+//	user:NAME      sent by NAME
+//	user~/REGEX/   sent by someone whose name matches REGEX
+//	badge:BADGE    sent by someone with BADGE, e.g. mod, vip or sub
+//	type:KIND      a message of KIND, e.g. chat, sub or moderation
+//	text:WORD      the text contains WORD
+//	text~/REGEX/   the text matches REGEX
+//	WORD           the name of the sender or the text contains WORD
+//
+// from works the same as user.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+var ErrEmptyFilter = errors.New("empty filter")
+
+// fieldAliases are other names for the fields.
+var fieldAliases = map[string]string{
+	"from": "user",
+}
+
+// Names the chat shows as icons, mapped to the badge names Twitch uses.
+var badgeAliases = map[string]string{
+	"mod":   "moderator",
+	"sub":   "subscriber",
+	"prime": "premium",
+}
+
+type term struct {
+	negated bool
+	match   func(msg message.Message) bool
+}
+
+type Filter struct {
+	expression string
+	terms      []term
+}
+
+// Parse parses a filter expression.
+func Parse(expression string) (*Filter, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, ErrEmptyFilter
+	}
+
+	f := &Filter{expression: expression}
+	for _, field := range fields(expression) {
+		t, err := parseTerm(field)
+		if err != nil {
+			return nil, err
+		}
+		f.terms = append(f.terms, t)
+	}
+	return f, nil
+}
+
+// fields splits an expression into its terms at spaces, except for those
+// inside the /REGEX/ of a ~ term.
+func fields(expression string) []string {
+	var result []string
+	var field strings.Builder
+	inRegex, escaped := false, false
+	for _, r := range expression {
+		switch {
+		case inRegex:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '/':
+				inRegex = false
+			}
+		case r == '/' && strings.HasSuffix(field.String(), "~"):
+			inRegex = true
+		case r == ' ' || r == '\t':
+			if field.Len() > 0 {
+				result = append(result, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteRune(r)
+	}
+	if field.Len() > 0 {
+		result = append(result, field.String())
+	}
+	return result
+}
+
+func parseTerm(field string) (term, error) {
+	var t term
+	if rest, ok := strings.CutPrefix(field, "-"); ok && rest != "" {
+		t.negated = true
+		field = rest
+	}
+
+	if key, pattern, ok := strings.Cut(field, "~"); ok {
+		if alias, ok := fieldAliases[key]; ok {
+			key = alias
+		}
+		re, err := parseRegex(pattern)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", field, err)
+		}
+		switch key {
+		case "user":
+			t.match = func(msg message.Message) bool {
+				sender := msg.Sender()
+				return sender.Name != "" && (re.MatchString(sender.Name) || re.MatchString(sender.DisplayName))
+			}
+		case "text":
+			t.match = func(msg message.Message) bool { return re.MatchString(msg.Text()) }
+		default:
+			return t, fmt.Errorf("%s: unknown field %q", field, key)
+		}
+		return t, nil
+	}
+
+	key, value, ok := strings.Cut(field, ":")
+	if !ok {
+		word := strings.ToLower(field)
+		t.match = func(msg message.Message) bool {
+			sender := msg.Sender()
+			return strings.Contains(strings.ToLower(sender.Name), word) ||
+				strings.Contains(strings.ToLower(sender.DisplayName), word) ||
+				strings.Contains(strings.ToLower(msg.Text()), word)
+		}
+		return t, nil
+	}
+	if value == "" {
+		return t, fmt.Errorf("%s: missing value", field)
+	}
+
+	value = strings.ToLower(value)
+	if alias, ok := fieldAliases[key]; ok {
+		key = alias
+	}
+	switch key {
+	case "user":
+		value = strings.TrimPrefix(value, "@")
+		t.match = func(msg message.Message) bool {
+			sender := msg.Sender()
+			return strings.EqualFold(sender.Name, value) || strings.EqualFold(sender.DisplayName, value)
+		}
+	case "badge":
+		if alias, ok := badgeAliases[value]; ok {
+			value = alias
+		}
+		t.match = func(msg message.Message) bool { return hasBadge(msg.Sender(), value) }
+	case "type":
+		kind := message.Kind(value)
+		if !slices.Contains(message.Kinds, kind) {
+			return t, fmt.Errorf("%s: unknown type, expected one of %s", field, joinKinds())
+		}
+		t.match = func(msg message.Message) bool { return msg.Kind() == kind }
+	case "text":
+		t.match = func(msg message.Message) bool {
+			return strings.Contains(strings.ToLower(msg.Text()), value)
+		}
+	default:
+		return t, fmt.Errorf("%s: unknown field %q", field, key)
+	}
+	return t, nil
+}
+
+func parseRegex(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) < 2 || !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return nil, errors.New("expected a /regex/")
+	}
+	return regexp.Compile(pattern[1 : len(pattern)-1])
+}
+
+func hasBadge(user twitch.User, badge string) bool {
+	if _, ok := user.Badges[badge]; ok {
+		return true
+	}
+	// The badges of our own messages are not always known.
+	switch badge {
+	case "moderator":
+		return user.IsMod
+	case "vip":
+		return user.IsVip
+	case "broadcaster":
+		return user.IsBroadcaster
+	}
+	return false
+}
+
+func joinKinds() string {
+	kinds := make([]string, len(message.Kinds))
+	for i, kind := range message.Kinds {
+		kinds[i] = string(kind)
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// Match reports whether a message passes the filter. A nil filter matches
+// every message.
+func (f *Filter) Match(msg message.Message) bool {
+	if f == nil {
+		return true
+	}
+	for _, t := range f.terms {
+		if t.match(msg) == t.negated {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expression
+}
@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+// messages are the messages the filters are tried on, by name.
+var messages = map[string]string{
+	"viewer":    "@badges=subscriber/12;color=#1E90FF;display-name=Viewer;id=1;room-id=1;tmi-sent-ts=1700000000000;user-id=42 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #lurkmode :hello world",
+	"moderator": "@badges=moderator/1;color=;display-name=ModUser;id=2;mod=1;room-id=1;tmi-sent-ts=1700000001000;user-id=43 :moduser!moduser@moduser.tmi.twitch.tv PRIVMSG #lurkmode :check https://example.com/a b",
+	"prime":     "@badges=premium/1,vip/1;color=;display-name=Primer;id=3;room-id=1;tmi-sent-ts=1700000002000;user-id=44;vip=1 :primer!primer@primer.tmi.twitch.tv PRIVMSG #lurkmode :Hello there",
+	"sub":       `@badges=;color=;display-name=Viewer;id=4;login=viewer;msg-id=sub;msg-param-sub-plan=1000;room-id=1;system-msg=Viewer\ssubscribed.;tmi-sent-ts=1700000003000;user-id=42 :tmi.twitch.tv USERNOTICE #lurkmode`,
+	"timeout":   "@ban-duration=600;room-id=1;target-user-id=45;tmi-sent-ts=1700000004000 :tmi.twitch.tv CLEARCHAT #lurkmode :spammer",
+}
+
+func matching(t *testing.T, f *Filter) []string {
+	t.Helper()
+
+	var names []string
+	for name, raw := range messages {
+		msg := message.ParseRaw(raw)
+		if msg == nil {
+			t.Fatalf("message %s was not parsed", name)
+		}
+		if f.Match(msg) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       []string
+	}{
+		{"hello", []string{"prime", "viewer"}},
+		{"HELLO", []string{"prime", "viewer"}},
+		{"viewer", []string{"sub", "viewer"}},
+		{"-hello", []string{"moderator", "sub", "timeout"}},
+		{"user:viewer", []string{"sub", "viewer"}},
+		{"from:viewer", []string{"sub", "viewer"}},
+		{"-from:viewer", []string{"moderator", "prime", "timeout"}},
+		{"user:@ModUser", []string{"moderator"}},
+		{"user~/^mod/", []string{"moderator"}},
+		{"from~/^mod/", []string{"moderator"}},
+		{"-user~/./", []string{"timeout"}},
+		{"badge:mod", []string{"moderator"}},
+		{"badge:moderator", []string{"moderator"}},
+		{"badge:sub", []string{"viewer"}},
+		{"badge:prime", []string{"prime"}},
+		{"badge:vip", []string{"prime"}},
+		{"-badge:sub -badge:mod", []string{"prime", "sub", "timeout"}},
+		{"type:chat", []string{"moderator", "prime", "viewer"}},
+		{"type:sub", []string{"sub"}},
+		{"type:moderation", []string{"timeout"}},
+		{"-type:chat", []string{"sub", "timeout"}},
+		{"text:world", []string{"viewer"}},
+		{"text~/^hello/", []string{"viewer"}},
+		{"text~/(?i)^hello/", []string{"prime", "viewer"}},
+		// A regex may contain spaces and escaped slashes.
+		{"text~/hello world/", []string{"viewer"}},
+		{"text~/\\.com\\/a b$/ type:chat", []string{"moderator"}},
+		{"type:chat  -text~/hello world/", []string{"moderator", "prime"}},
+		{"hello badge:sub", []string{"viewer"}},
+	}
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			f, err := Parse(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matching(t, f); !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			if f.String() != strings.TrimSpace(test.expression) {
+				t.Errorf("got expression %q, want %q", f.String(), test.expression)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"text~/[/", "missing closing ]"},
+		{"text~hello", "expected a /regex/"},
+		{"text~/hello", "expected a /regex/"},
+		{"type:emote", "unknown type"},
+		{"nick:viewer", `unknown field "nick"`},
+		{"badge~/mod/", `unknown field "badge"`},
+		{"user:", "missing value"},
+	}
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := Parse(test.expression)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestEmptyFilter(t *testing.T) {
+	for _, expression := range []string{"", "   "} {
+		if _, err := Parse(expression); !errors.Is(err, ErrEmptyFilter) {
+			t.Errorf("%q: got %v, want ErrEmptyFilter", expression, err)
+		}
+	}
+	var f *Filter
+	if !f.Match(message.ParseRaw(messages["viewer"])) {
+		t.Error("a nil filter doesn't match every message")
+	}
+}
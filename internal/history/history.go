@@ -0,0 +1,152 @@
+// Package history keeps the messages that no longer fit in memory on disk, so
+// that they can be read back later in the session.
+//
+// Every channel gets an append-only file of raw IRC lines. The offsets of the
+// lines are kept in memory, which makes reading any range of them cheap. The
+// files are temporary and removed when the store is closed.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrClosed = errors.New("history store is closed")
+
+type channelFile struct {
+	file    *os.File
+	offsets []int64
+	size    int64
+}
+
+type Store struct {
+	mutex    sync.Mutex
+	dir      string
+	channels map[string]*channelFile
+	closed   bool
+}
+
+// DefaultDir is where the history is stored unless another directory is given.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lurkmode", "history"), nil
+}
+
+// Open creates a store that keeps its files in dir.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{
+		dir:      dir,
+		channels: map[string]*channelFile{},
+	}, nil
+}
+
+func (s *Store) channel(name string) (*channelFile, error) {
+	if c, ok := s.channels[name]; ok {
+		return c, nil
+	}
+
+	file, err := os.CreateTemp(s.dir, name+"-*.irc")
+	if err != nil {
+		return nil, err
+	}
+	c := &channelFile{file: file}
+	s.channels[name] = c
+	return c, nil
+}
+
+// Append adds a raw IRC line to the history of a channel.
+func (s *Store) Append(channel, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	raw = strings.TrimRight(raw, "\r\n")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	c, err := s.channel(channel)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.file.WriteAt([]byte(raw+"\n"), c.size)
+	if err != nil {
+		return fmt.Errorf("writing history of #%s: %w", channel, err)
+	}
+	c.offsets = append(c.offsets, c.size)
+	c.size += int64(n)
+	return nil
+}
+
+// Len returns the number of lines stored for a channel.
+func (s *Store) Len(channel string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if c, ok := s.channels[channel]; ok {
+		return len(c.offsets)
+	}
+	return 0
+}
+
+// Read returns the lines start to end, exclusive, of a channel, oldest first.
+func (s *Store) Read(channel string, start, end int) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+	c, ok := s.channels[channel]
+	if !ok {
+		return nil, nil
+	}
+	start = max(start, 0)
+	end = min(end, len(c.offsets))
+	if start >= end {
+		return nil, nil
+	}
+
+	from := c.offsets[start]
+	to := c.size
+	if end < len(c.offsets) {
+		to = c.offsets[end]
+	}
+	data := make([]byte, to-from)
+	if _, err := c.file.ReadAt(data, from); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading history of #%s: %w", channel, err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// Close removes the files of the store.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var errs []error
+	for _, c := range s.channels {
+		errs = append(errs, c.file.Close(), os.Remove(c.file.Name()))
+	}
+	return errors.Join(errs...)
+}
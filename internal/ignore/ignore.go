@@ -0,0 +1,200 @@
+// Package ignore keeps the users and the message patterns we never want to
+// see, e.g. chat bots and spammers. The list is kept in ignore.json next to
+// the config file:
+//
+//	{
+//	  "users": [{"id": "19264788", "name": "nightbot"}],
+//	  "patterns": ["^!\\w+"]
+//	}
+package ignore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/config"
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+const fileName = "ignore.json"
+
+// Bots are the common chat bots, which can be ignored all at once.
+var Bots = []string{
+	"nightbot",
+	"streamelements",
+	"streamlabs",
+	"moobot",
+	"fossabot",
+	"wizebot",
+	"sery_bot",
+	"soundalerts",
+	"kofistreambot",
+	"botrixoficial",
+}
+
+type User struct {
+	// ID is empty for users that were ignored by name only. It keeps them
+	// ignored when they change their name.
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type List struct {
+	Users    []User   `json:"users"`
+	Patterns []string `json:"patterns"`
+
+	path     string
+	patterns []*regexp.Regexp
+}
+
+func Path() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// Load reads the list from path. A missing file is an empty list, which is
+// created once something is ignored.
+func Load(path string) (*List, error) {
+	l := &List{Users: []User{}, Patterns: []string{}, path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return l, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, pattern := range l.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: invalid pattern %q: %w", path, pattern, err)
+		}
+		l.patterns = append(l.patterns, re)
+	}
+	return l, nil
+}
+
+// Save writes the list back to its file.
+func (l *List) Save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that a failed write does not lose
+	// the list.
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// Matches reports whether msg was sent by an ignored user or says something
+// ignored. Messages from Twitch itself, like moderation, are never ignored.
+func (l *List) Matches(msg message.Message) bool {
+	if l == nil {
+		return false
+	}
+
+	sender := msg.Sender()
+	if sender.Name == "" {
+		return false
+	}
+	if slices.ContainsFunc(l.Users, func(u User) bool { return u.matches(sender) }) {
+		return true
+	}
+
+	text := msg.Text()
+	if text == "" {
+		return false
+	}
+	for _, pattern := range l.patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u User) matches(user twitch.User) bool {
+	if u.ID != "" && user.ID != "" {
+		return u.ID == user.ID
+	}
+	return strings.EqualFold(u.Name, user.Name)
+}
+
+// IgnoreUser adds user to the list. It reports false if they were ignored
+// already.
+func (l *List) IgnoreUser(user twitch.User) bool {
+	if slices.ContainsFunc(l.Users, func(u User) bool { return u.matches(user) }) {
+		return false
+	}
+	l.Users = append(l.Users, User{ID: user.ID, Name: strings.ToLower(user.Name)})
+	return true
+}
+
+// UnignoreUser removes the user with the name from the list. It reports false
+// if they were not ignored.
+func (l *List) UnignoreUser(name string) bool {
+	count := len(l.Users)
+	l.Users = slices.DeleteFunc(l.Users, func(u User) bool { return strings.EqualFold(u.Name, name) })
+	return len(l.Users) < count
+}
+
+// IgnorePattern hides the messages matching a regular expression. It reports
+// false if the pattern was ignored already.
+func (l *List) IgnorePattern(pattern string) (bool, error) {
+	if slices.Contains(l.Patterns, pattern) {
+		return false, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern: %w", err)
+	}
+	l.Patterns = append(l.Patterns, pattern)
+	l.patterns = append(l.patterns, re)
+	return true, nil
+}
+
+// UnignorePattern shows the messages matching a pattern again. It reports
+// false if the pattern was not ignored.
+func (l *List) UnignorePattern(pattern string) bool {
+	index := slices.Index(l.Patterns, pattern)
+	if index < 0 {
+		return false
+	}
+	l.Patterns = slices.Delete(l.Patterns, index, index+1)
+	l.patterns = slices.Delete(l.patterns, index, index+1)
+	return true
+}
+
+// String lists what is ignored, e.g. "nightbot, /^!\w+/".
+func (l *List) String() string {
+	var items []string
+	for _, u := range l.Users {
+		items = append(items, u.Name)
+	}
+	for _, pattern := range l.Patterns {
+		items = append(items, "/"+pattern+"/")
+	}
+	return strings.Join(items, ", ")
+}
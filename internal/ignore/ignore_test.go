@@ -0,0 +1,148 @@
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/message"
+)
+
+// chatMessage is a message of login in #lurkmode. An empty id leaves out the
+// user-id tag.
+func chatMessage(id, login, text string) message.Message {
+	tags := "badges=;color=;display-name=" + login + ";id=msg-1;room-id=1;tmi-sent-ts=1700000000000"
+	if id != "" {
+		tags += ";user-id=" + id
+	}
+	return message.ParseRaw(fmt.Sprintf("@%s :%[2]s!%[2]s@%[2]s.tmi.twitch.tv PRIVMSG #lurkmode :%[3]s", tags, login, text))
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	l, err := Load(filepath.Join(t.TempDir(), fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Users) != 0 || len(l.Patterns) != 0 {
+		t.Errorf("got %s, want an empty list", l)
+	}
+	if l.Matches(chatMessage("42", "viewer", "hello")) {
+		t.Error("an empty list matches")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lurkmode", fileName)
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.IgnoreUser(twitch.User{ID: "19264788", Name: "Nightbot"})
+	l.IgnoreUser(twitch.User{Name: "spammer"})
+	if _, err := l.IgnorePattern(`^!\w+`); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file is left behind: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUsers := []User{{ID: "19264788", Name: "nightbot"}, {Name: "spammer"}}
+	if !slices.Equal(loaded.Users, wantUsers) {
+		t.Errorf("got users %v, want %v", loaded.Users, wantUsers)
+	}
+	if !slices.Equal(loaded.Patterns, []string{`^!\w+`}) {
+		t.Errorf("got patterns %q", loaded.Patterns)
+	}
+	// The patterns are compiled again.
+	if !loaded.Matches(chatMessage("42", "viewer", "!commands")) {
+		t.Error("the loaded pattern does not match")
+	}
+}
+
+func TestLoadInvalidPattern(t *testing.T) {
+	path := filepath.Join(t.TempDir(), fileName)
+	if err := os.WriteFile(path, []byte(`{"users": [], "patterns": ["[a-"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("got no error for an invalid pattern")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	l := &List{Users: []User{{ID: "42", Name: "renamed"}, {Name: "nightbot"}}}
+	if _, err := l.IgnorePattern(`^!\w+`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		msg  message.Message
+		want bool
+	}{
+		{"same ID under a new name", chatMessage("42", "newname", "hi"), true},
+		{"same name under another ID", chatMessage("43", "renamed", "hi"), false},
+		{"same name without an ID", chatMessage("", "renamed", "hi"), true},
+		{"name only, in another case", chatMessage("99", "NightBot", "hi"), true},
+		{"someone else", chatMessage("7", "viewer", "hi"), false},
+		{"pattern", chatMessage("7", "viewer", "!commands"), true},
+		{"pattern not at the start", chatMessage("7", "viewer", "try !commands"), false},
+		{"moderation", message.ParseRaw("@ban-duration=600;room-id=1;target-user-id=42;tmi-sent-ts=1700000000000 :tmi.twitch.tv CLEARCHAT #lurkmode :renamed"), false},
+	}
+	for _, test := range tests {
+		if got := l.Matches(test.msg); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+
+	var none *List
+	if none.Matches(chatMessage("42", "renamed", "hi")) {
+		t.Error("a nil list matches")
+	}
+}
+
+func TestIgnoreAndUnignore(t *testing.T) {
+	l := &List{}
+	if !l.IgnoreUser(twitch.User{ID: "42", Name: "Viewer"}) {
+		t.Error("ignoring a user reported false")
+	}
+	if l.IgnoreUser(twitch.User{ID: "42", Name: "renamed"}) {
+		t.Error("ignoring the same ID again reported true")
+	}
+	if l.IgnoreUser(twitch.User{Name: "VIEWER"}) {
+		t.Error("ignoring the same name again reported true")
+	}
+
+	if ok, err := l.IgnorePattern("spam+"); !ok || err != nil {
+		t.Errorf("ignoring a pattern: %t, %v", ok, err)
+	}
+	if ok, _ := l.IgnorePattern("spam+"); ok {
+		t.Error("ignoring the same pattern again reported true")
+	}
+	if _, err := l.IgnorePattern("[a-"); err == nil {
+		t.Error("got no error for an invalid pattern")
+	}
+	if got := l.String(); got != "viewer, /spam+/" {
+		t.Errorf("got %q", got)
+	}
+
+	if !l.UnignoreUser("Viewer") || l.UnignoreUser("viewer") {
+		t.Error("unignoring should only report true for an ignored user")
+	}
+	if !l.UnignorePattern("spam+") || l.UnignorePattern("spam+") {
+		t.Error("unignoring should only report true for an ignored pattern")
+	}
+	if l.Matches(chatMessage("42", "viewer", "spammm")) {
+		t.Error("the list still matches after unignoring everything")
+	}
+}
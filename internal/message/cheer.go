@@ -0,0 +1,120 @@
+package message
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/nextthang/lurkmode/internal/stylebuilder"
+)
+
+// The global cheermote prefixes. Channels can have their own custom ones,
+// which we can't know about without the Helix API.
+var cheermotePrefixes = map[string]struct{}{}
+
+func init() {
+	for _, prefix := range []string{
+		"Cheer", "DoodleCheer", "BibleThump", "cheerwhal", "Corgo", "Scoops", "uni",
+		"ShowLove", "Party", "SeemsGood", "Pride", "Kappa", "FrankerZ", "HeyGuys",
+		"DansGame", "EleGiggle", "TriHard", "Kreygasm", "4Head", "SwiftRage",
+		"NotLikeThis", "FailFish", "VoHiYo", "PJSalt", "MrDestructoid", "bday",
+		"RIPCheer", "Shamrock", "BitBoss", "Streamlabs", "Muxy", "HolidayCheer",
+		"Goal", "Anon", "Charity",
+	} {
+		cheermotePrefixes[strings.ToLower(prefix)] = struct{}{}
+	}
+}
+
+// Cheermote is a token like Cheer100 in a message. Start and End are
+// inclusive rune offsets into the message text.
+type Cheermote struct {
+	Start  int
+	End    int
+	Prefix string
+	Amount int
+}
+
+type cheerTier struct {
+	minimum int
+	style   lipgloss.Style
+}
+
+// Sorted from the highest tier to the lowest.
+var cheerTiers = []cheerTier{
+	{minimum: 10000, style: lipgloss.NewStyle().Foreground(lipgloss.Color("#f43021")).Bold(true)},
+	{minimum: 5000, style: lipgloss.NewStyle().Foreground(lipgloss.Color("#0099fe")).Bold(true)},
+	{minimum: 1000, style: lipgloss.NewStyle().Foreground(lipgloss.Color("#1db2a5")).Bold(true)},
+	{minimum: 100, style: lipgloss.NewStyle().Foreground(lipgloss.Color("#9c3ee8")).Bold(true)},
+	{minimum: 1, style: lipgloss.NewStyle().Foreground(lipgloss.Color("#979797")).Bold(true)},
+}
+
+func cheerTierStyle(amount int) lipgloss.Style {
+	for _, tier := range cheerTiers {
+		if amount >= tier.minimum {
+			return tier.style
+		}
+	}
+	return cheerTiers[len(cheerTiers)-1].style
+}
+
+func parseCheermote(word string) (string, int, bool) {
+	split := strings.LastIndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) + 1
+	if split == 0 || split == len(word) {
+		return "", 0, false
+	}
+
+	prefix := word[:split]
+	if _, ok := cheermotePrefixes[strings.ToLower(prefix)]; !ok {
+		return "", 0, false
+	}
+	amount, err := strconv.Atoi(word[split:])
+	if err != nil || amount <= 0 {
+		return "", 0, false
+	}
+	return prefix, amount, true
+}
+
+func parseCheermotes(text string) []Cheermote {
+	var cheermotes []Cheermote
+	runes := []rune(text)
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && runes[i] != ' ' {
+			continue
+		}
+		if i > start {
+			if prefix, amount, ok := parseCheermote(string(runes[start:i])); ok {
+				cheermotes = append(cheermotes, Cheermote{
+					Start:  start,
+					End:    i - 1,
+					Prefix: prefix,
+					Amount: amount,
+				})
+			}
+		}
+		start = i + 1
+	}
+	return cheermotes
+}
+
+type cheerMessage struct {
+	channelMessage
+	Bits int
+}
+
+func (m *cheerMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderReply(options, builder)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(" cheered ")
+	builder.WriteStringWithStyle(fmt.Sprintf("%d bits", m.Bits), cheerTierStyle(m.Bits))
+	if m.Message != "" {
+		builder.WriteString(": ")
+		m.renderText(options, builder)
+	}
+	return builder.String()
+}
+
+func (m *cheerMessage) isUserNotice() {}
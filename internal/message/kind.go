@@ -0,0 +1,49 @@
+package message
+
+// Kind is a coarse category of messages, e.g. to filter on.
+type Kind string
+
+const (
+	KindChat         Kind = "chat"
+	KindCheer        Kind = "cheer"
+	KindSub          Kind = "sub"
+	KindGift         Kind = "gift"
+	KindRaid         Kind = "raid"
+	KindAnnouncement Kind = "announcement"
+	KindNotice       Kind = "notice"
+	KindModeration   Kind = "moderation"
+)
+
+var Kinds = []Kind{
+	KindChat,
+	KindCheer,
+	KindSub,
+	KindGift,
+	KindRaid,
+	KindAnnouncement,
+	KindNotice,
+	KindModeration,
+}
+
+func (m *channelMessage) Kind() Kind          { return KindChat }
+func (m *cheerMessage) Kind() Kind            { return KindCheer }
+func (m *subMessage) Kind() Kind              { return KindSub }
+func (m *resubMessage) Kind() Kind            { return KindSub }
+func (m *primePaidUpgradeMessage) Kind() Kind { return KindSub }
+func (m *giftPaidUpgradeMessage) Kind() Kind  { return KindSub }
+func (m *subGiftMessage) Kind() Kind          { return KindGift }
+func (m *subMysteryGiftMessage) Kind() Kind   { return KindGift }
+func (m *payForwardMessage) Kind() Kind       { return KindGift }
+func (m *rewardGiftMessage) Kind() Kind       { return KindGift }
+func (m *raidMessage) Kind() Kind             { return KindRaid }
+func (m *unraidMessage) Kind() Kind           { return KindRaid }
+func (m *announcementMessage) Kind() Kind     { return KindAnnouncement }
+func (m *bitsBadgeTierMessage) Kind() Kind    { return KindNotice }
+func (m *ritualMessage) Kind() Kind           { return KindNotice }
+func (m *viewerMilestoneMessage) Kind() Kind  { return KindNotice }
+func (m *charityDonationMessage) Kind() Kind  { return KindNotice }
+func (m *systemNoticeMessage) Kind() Kind     { return KindNotice }
+func (m *clearChatMessage) Kind() Kind        { return KindModeration }
+func (m *timeoutMessage) Kind() Kind          { return KindModeration }
+func (m *banMessage) Kind() Kind              { return KindModeration }
+func (m *deleteMessage) Kind() Kind           { return KindModeration }
@@ -3,12 +3,15 @@ package message
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/lipgloss/v2"
 	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/emotes"
 	"github.com/nextthang/lurkmode/internal/stylebuilder"
 )
 
@@ -49,25 +52,46 @@ func parseSubLength(num int) (string, int) {
 
 const timeFormat = "[" + time.Kitchen + "] "
 
-var (
-	timeStyle             = lipgloss.NewStyle().Foreground(lipgloss.Color("247"))
-	broadcasterBadgeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#e81815"))
-	vipBadgeStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("#e005b9"))
-	modBadgeStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("#00ad03"))
-	subBadgeStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("#6441a5"))
-)
-
 func renderColoredName(user twitch.User, style lipgloss.Style) string {
-	if user.Color == "" {
+	color := userColor(user)
+	if color == "" || !userColors {
 		return style.Render(user.DisplayName)
 	} else {
 		return lipgloss.NewStyle().
 			Inherit(style).
-			Foreground(lipgloss.Color(user.Color)).
+			Foreground(lipgloss.Color(color)).
 			Render(user.DisplayName)
 	}
 }
 
+// writeHighlighted writes text, marking everything that matches highlight.
+func writeHighlighted(builder *stylebuilder.StyleBuilder, text string, highlight *regexp.Regexp) {
+	if highlight == nil {
+		builder.WriteString(text)
+		return
+	}
+
+	position := 0
+	for _, match := range highlight.FindAllStringIndex(text, -1) {
+		if match[0] == match[1] {
+			continue
+		}
+		builder.WriteString(text[position:match[0]])
+		builder.WriteStringWithStyle(text[match[0]:match[1]], highlightStyle)
+		position = match[1]
+	}
+	builder.WriteString(text[position:])
+}
+
+// writeLineBreak starts a new line, or separates the parts of a plain message.
+func writeLineBreak(options RenderOptions, builder *stylebuilder.StyleBuilder) {
+	if options.Plain {
+		builder.WriteString(" | ")
+		return
+	}
+	builder.WriteString("\n")
+}
+
 func renderUserTags(user twitch.User, style lipgloss.Style) string {
 	var tags []string
 	if user.IsBroadcaster {
@@ -111,38 +135,96 @@ func parseMsgParamsKeyString(message *twitch.UserNoticeMessage, key string, defa
 	return defaultValue
 }
 
+type RenderOptions struct {
+	Time bool
+	// RevealDeleted renders deleted messages struck through instead of
+	// replacing their text.
+	RevealDeleted bool
+	// Highlight marks matching names and text, e.g. for search results.
+	Highlight *regexp.Regexp
+	// Plain renders a single line with emotes as their names, for output
+	// that does not end up in a terminal.
+	Plain bool
+}
+
 type Message interface {
-	Render(renderTime bool, style lipgloss.Style) string
+	Render(options RenderOptions, style lipgloss.Style) string
 	ChannelName() string
+	// Sender is the user who sent the message, or the zero User for
+	// messages that come from Twitch itself.
+	Sender() twitch.User
+	// Text is what the user wrote, if anything.
+	Text() string
+	Kind() Kind
+	// Raw is the IRC line the message was parsed from.
+	Raw() string
+	SentAt() time.Time
+}
+
+// Deletable is implemented by every message that moderators can remove.
+type Deletable interface {
+	MarkDeleted()
+	Deleted() bool
 }
 
 type UserNotice interface {
 	isUserNotice()
 }
 
+// NewMessage converts a message from go-twitch-irc. It returns nil for the
+// messages that are not shown in chat.
 func NewMessage(message twitch.Message) Message {
+	msg, raw := newMessage(message)
+	if getter, ok := msg.(baseMessageGetter); ok {
+		getter.base().raw = raw
+	}
+	return msg
+}
+
+// ParseRaw parses a raw IRC line, like the ones returned by Message.Raw.
+func ParseRaw(raw string) Message {
+	return NewMessage(twitch.ParseMessage(raw))
+}
+
+func newMessage(message twitch.Message) (Message, string) {
 	switch v := message.(type) {
 	case *twitch.PrivateMessage:
-		return &channelMessage{
+		msg := channelMessage{
 			baseMessage: baseMessage{
+				ID:      v.ID,
 				User:    v.User,
 				Time:    v.Time,
 				Channel: v.Channel,
+				RoomID:  v.RoomID,
 			},
 			Message: v.Message,
+			Emotes:  emotes.FromTwitch(v.Emotes),
+			Reply:   v.Reply,
+		}
+		msg.stripReplyMention()
+		if v.Bits > 0 {
+			msg.Cheermotes = parseCheermotes(msg.Message)
+			return &cheerMessage{channelMessage: msg, Bits: v.Bits}, v.Raw
 		}
+		return &msg, v.Raw
 	case *twitch.UserNoticeMessage:
-		return parseUserNoticeMessage(v)
+		return parseUserNoticeMessage(v), v.Raw
+	case *twitch.ClearChatMessage:
+		return parseClearChatMessage(v), v.Raw
+	case *twitch.ClearMessage:
+		return parseClearMessage(v), v.Raw
 	default:
-		return nil
+		return nil, ""
 	}
 }
 
 func newBaseMessageFromNotice(message *twitch.UserNoticeMessage) baseMessage {
 	return baseMessage{
+		ID:      message.ID,
 		User:    message.User,
 		Time:    message.Time,
 		Channel: message.Channel,
+		RoomID:  message.RoomID,
 	}
 }
 
@@ -150,30 +232,50 @@ func newChannelMessageFromNotice(message *twitch.UserNoticeMessage) channelMessa
 	return channelMessage{
 		baseMessage: newBaseMessageFromNotice(message),
 		Message:     message.Message,
+		Emotes:      emotes.FromTwitch(message.Emotes),
 	}
 }
 
 type userNoticeParser func(message *twitch.UserNoticeMessage) Message
 
 var userNoticeParsers = map[string]userNoticeParser{
-	"sub":            parseSubMessage,
-	"resub":          parseResubMessage,
-	"subgift":        parseSubGiftMessage,
-	"submysterygift": parseSubMysteryGiftMessage,
-	"raid":           parseRaidMessage,
+	"sub":                 parseSubMessage,
+	"resub":               parseResubMessage,
+	"subgift":             parseSubGiftMessage,
+	"anonsubgift":         parseSubGiftMessage,
+	"submysterygift":      parseSubMysteryGiftMessage,
+	"anonsubmysterygift":  parseSubMysteryGiftMessage,
+	"giftpaidupgrade":     parseGiftPaidUpgradeMessage,
+	"anongiftpaidupgrade": parseAnonGiftPaidUpgradeMessage,
+	"primepaidupgrade":    parsePrimePaidUpgradeMessage,
+	"communitypayforward": parsePayForwardMessage,
+	"standardpayforward":  parsePayForwardMessage,
+	"rewardgift":          parseRewardGiftMessage,
+	"raid":                parseRaidMessage,
+	"unraid":              parseUnraidMessage,
+	"ritual":              parseRitualMessage,
+	"bitsbadgetier":       parseBitsBadgeTierMessage,
+	"announcement":        parseAnnouncementMessage,
+	"viewermilestone":     parseViewerMilestoneMessage,
+	"charitydonation":     parseCharityDonationMessage,
+}
+
+func init() {
+	// Registered here as shared chat notices dispatch back into userNoticeParsers.
+	userNoticeParsers["sharedchatnotice"] = parseSharedChatNoticeMessage
 }
 
 func parseSubMessage(message *twitch.UserNoticeMessage) Message {
 	return &subMessage{
 		channelMessage: newChannelMessageFromNotice(message),
-		Plan:           parseSubPlan(message.MsgParams["sub-plan"]),
+		Plan:           parseSubPlan(message.MsgParams["msg-param-sub-plan"]),
 	}
 }
 
 func parseResubMessage(message *twitch.UserNoticeMessage) Message {
 	return &resubMessage{
 		channelMessage:   newChannelMessageFromNotice(message),
-		Plan:             parseSubPlan(message.MsgParams["sub-plan"]),
+		Plan:             parseSubPlan(message.MsgParams["msg-param-sub-plan"]),
 		CumulativeMonths: parseMsgParamsKeyUint(message, "msg-param-cumulative-months", 1),
 		CurrentStreak:    parseMsgParamsKeyUint(message, "msg-param-streak-months", 0),
 	}
@@ -187,14 +289,14 @@ func parseSubGiftMessage(message *twitch.UserNoticeMessage) Message {
 			Name:        parseMsgParamsKeyString(message, "msg-param-recipient-user-name", ""),
 			DisplayName: parseMsgParamsKeyString(message, "msg-param-recipient-display-name", ""),
 		},
-		Plan: parseSubPlan(message.MsgParams["sub-plan"]),
+		Plan: parseSubPlan(message.MsgParams["msg-param-sub-plan"]),
 	}
 }
 
 func parseSubMysteryGiftMessage(message *twitch.UserNoticeMessage) Message {
 	return &subMysteryGiftMessage{
 		baseMessage:    newBaseMessageFromNotice(message),
-		Plan:           parseSubPlan(message.MsgParams["sub-plan"]),
+		Plan:           parseSubPlan(message.MsgParams["msg-param-sub-plan"]),
 		GiftCount:      parseMsgParamsKeyUint(message, "msg-param-mass-gift-count", 1),
 		TotalGiftCount: parseMsgParamsKeyUint(message, "msg-param-sender-count", 0),
 	}
@@ -211,21 +313,51 @@ func parseUserNoticeMessage(message *twitch.UserNoticeMessage) Message {
 	if parser, ok := userNoticeParsers[message.MsgID]; ok {
 		return parser(message)
 	}
-	log.Printf("Unknown user notice message type: %s %+v", message.MsgID, message)
-	return nil
+	if _, logged := unknownNotices.LoadOrStore(message.MsgID, true); !logged {
+		log.Printf("Unknown user notice message type %q, showing its system message", message.MsgID)
+	}
+	return parseSystemNoticeMessage(message)
 }
 
+// unknownNotices holds the msg-ids without a parser that were logged, so that
+// busy channels don't log the same one over and over.
+var unknownNotices sync.Map
+
 type baseMessage struct {
+	ID      string
 	User    twitch.User
 	Time    time.Time
 	Channel string
+	RoomID  string
+	deleted bool
+	raw     string
+}
+
+func (m *baseMessage) base() *baseMessage {
+	return m
 }
 
-func (m *baseMessage) renderHeader(renderTime bool, style lipgloss.Style, builder *stylebuilder.StyleBuilder) {
-	if renderTime {
+func (m *baseMessage) MarkDeleted() {
+	m.deleted = true
+}
+
+func (m *baseMessage) Deleted() bool {
+	return m.deleted
+}
+
+func (m *baseMessage) renderTime(options RenderOptions, builder *stylebuilder.StyleBuilder) {
+	if options.Time {
 		builder.WriteStringWithStyle(m.Time.Format(timeFormat), timeStyle)
 	}
+}
+
+func (m *baseMessage) renderHeader(options RenderOptions, style lipgloss.Style, builder *stylebuilder.StyleBuilder) {
+	m.renderTime(options, builder)
 	builder.WriteStyledString(renderUserTags(m.User, style))
+	if options.Highlight != nil && options.Highlight.MatchString(m.User.DisplayName) {
+		builder.WriteStringWithStyle(m.User.DisplayName, highlightStyle)
+		return
+	}
 	builder.WriteStyledString(renderColoredName(m.User, style))
 }
 
@@ -233,17 +365,86 @@ func (m *baseMessage) ChannelName() string {
 	return m.Channel
 }
 
+func (m *baseMessage) Sender() twitch.User {
+	return m.User
+}
+
+func (m *baseMessage) Text() string {
+	return ""
+}
+
+func (m *baseMessage) Raw() string {
+	return m.raw
+}
+
+func (m *baseMessage) SentAt() time.Time {
+	return m.Time
+}
+
 type channelMessage struct {
 	baseMessage
-	Message string
+	Message    string
+	Emotes     []emotes.Occurrence
+	Cheermotes []Cheermote
+	Reply      *twitch.Reply
+}
+
+func (m *channelMessage) renderText(options RenderOptions, builder *stylebuilder.StyleBuilder) {
+	if m.deleted {
+		if !options.RevealDeleted {
+			builder.WriteStringWithStyle("<message deleted>", deletedStyle)
+			return
+		}
+		deletedBuilder := stylebuilder.NewStyleBuilder(builder.Style.Strikethrough(true))
+		m.renderEmotes(options, deletedBuilder)
+		builder.WriteStyledString(deletedBuilder.String())
+		return
+	}
+	m.renderEmotes(options, builder)
+}
+
+func (m *channelMessage) Text() string {
+	return m.Message
+}
+
+func (m *channelMessage) renderEmotes(options RenderOptions, builder *stylebuilder.StyleBuilder) {
+	text := []rune(m.Message)
+	position := 0
+	for _, occurrence := range emotes.Tokenize(m.RoomID, m.Message, m.Emotes) {
+		if occurrence.Start < position || occurrence.End >= len(text) || occurrence.Start > occurrence.End {
+			continue
+		}
+		m.renderCheermotes(options, builder, text, position, occurrence.Start)
+		if options.Plain {
+			builder.WriteString(occurrence.Emote.Name)
+		} else {
+			builder.WriteStyledString(emotes.Render(occurrence.Emote, builder.Style))
+		}
+		position = occurrence.End + 1
+	}
+	m.renderCheermotes(options, builder, text, position, len(text))
+}
+
+// renderCheermotes writes text[start:end], colouring the cheermotes in it.
+func (m *channelMessage) renderCheermotes(options RenderOptions, builder *stylebuilder.StyleBuilder, text []rune, start, end int) {
+	for _, cheermote := range m.Cheermotes {
+		if cheermote.Start < start || cheermote.End >= end {
+			continue
+		}
+		writeHighlighted(builder, string(text[start:cheermote.Start]), options.Highlight)
+		builder.WriteStringWithStyle(string(text[cheermote.Start:cheermote.End+1]), cheerTierStyle(cheermote.Amount))
+		start = cheermote.End + 1
+	}
+	writeHighlighted(builder, string(text[start:end]), options.Highlight)
 }
 
-func (m *channelMessage) Render(renderTime bool, style lipgloss.Style) string {
+func (m *channelMessage) Render(options RenderOptions, style lipgloss.Style) string {
 	builder := stylebuilder.NewStyleBuilder(style)
-	m.renderHeader(renderTime, style, builder)
+	m.renderReply(options, builder)
+	m.renderHeader(options, style, builder)
 
 	builder.WriteString(": ")
-	builder.WriteString(m.Message)
+	m.renderText(options, builder)
 	return builder.String()
 }
 
@@ -252,19 +453,19 @@ type subMessage struct {
 	Plan SubPlan // msg-param-sub-plan
 }
 
-func (m *subMessage) Render(renderTime bool, style lipgloss.Style) string {
+func (m *subMessage) Render(options RenderOptions, style lipgloss.Style) string {
 	builder := stylebuilder.NewStyleBuilder(style)
-	m.renderHeader(renderTime, style, builder)
+	m.renderHeader(options, style, builder)
 
 	builder.WriteString(" subscribed")
 	if m.Plan == SubPlanPrime {
 		builder.WriteString(" with Prime")
 	} else {
-		builder.WriteString(fmt.Sprintf("at Tier %d", m.Plan))
+		builder.WriteString(fmt.Sprintf(" at Tier %d", m.Plan))
 	}
 	if m.Message != "" {
-		builder.WriteString("\n")
-		builder.WriteStyledString(m.channelMessage.Render(renderTime, style))
+		writeLineBreak(options, builder)
+		builder.WriteStyledString(m.channelMessage.Render(options, style))
 	}
 	return builder.String()
 }
@@ -278,9 +479,9 @@ type resubMessage struct {
 	CurrentStreak    uint32  // msg-param-streak-months, if msg-param-should-share-streak is 1
 }
 
-func (m *resubMessage) Render(renderTime bool, style lipgloss.Style) string {
+func (m *resubMessage) Render(options RenderOptions, style lipgloss.Style) string {
 	builder := stylebuilder.NewStyleBuilder(style)
-	m.renderHeader(renderTime, style, builder)
+	m.renderHeader(options, style, builder)
 
 	builder.WriteString(" resubscribed")
 	if m.Plan == SubPlanPrime {
@@ -295,8 +496,8 @@ func (m *resubMessage) Render(renderTime bool, style lipgloss.Style) string {
 	}
 
 	if m.Message != "" {
-		builder.WriteString("\n")
-		builder.WriteStyledString(m.channelMessage.Render(renderTime, style))
+		writeLineBreak(options, builder)
+		builder.WriteStyledString(m.channelMessage.Render(options, style))
 	}
 	return builder.String()
 }
@@ -309,9 +510,9 @@ type subGiftMessage struct {
 	Plan     SubPlan // msg-param-sub-plan
 }
 
-func (m *subGiftMessage) Render(renderTime bool, style lipgloss.Style) string {
+func (m *subGiftMessage) Render(options RenderOptions, style lipgloss.Style) string {
 	builder := stylebuilder.NewStyleBuilder(style)
-	m.renderHeader(renderTime, style, builder)
+	m.renderHeader(options, style, builder)
 	builder.WriteString(fmt.Sprintf(" gifted a Tier %d subscription to %s!", m.Plan, m.Receiver.DisplayName))
 	return builder.String()
 }
@@ -325,9 +526,9 @@ type subMysteryGiftMessage struct {
 	TotalGiftCount uint32  // msg-param-sender-count
 }
 
-func (m *subMysteryGiftMessage) Render(renderTime bool, style lipgloss.Style) string {
+func (m *subMysteryGiftMessage) Render(options RenderOptions, style lipgloss.Style) string {
 	builder := stylebuilder.NewStyleBuilder(style)
-	m.renderHeader(renderTime, style, builder)
+	m.renderHeader(options, style, builder)
 	builder.WriteString(fmt.Sprintf(" gifted %d Tier %d subscriptions!", m.GiftCount, m.Plan))
 	if m.TotalGiftCount > 0 {
 		builder.WriteString(fmt.Sprintf(" Total gifted subscriptions: %d", m.TotalGiftCount))
@@ -343,9 +544,9 @@ type raidMessage struct {
 	ViewerCount uint32 // msg-param-viewerCount
 }
 
-func (m *raidMessage) Render(renderTime bool, style lipgloss.Style) string {
+func (m *raidMessage) Render(options RenderOptions, style lipgloss.Style) string {
 	builder := stylebuilder.NewStyleBuilder(style)
-	m.renderHeader(renderTime, style, builder)
+	m.renderHeader(options, style, builder)
 
 	builder.WriteString(fmt.Sprintf(" raided with %d viewers!", m.ViewerCount))
 
@@ -0,0 +1,145 @@
+package message
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/stylebuilder"
+)
+
+// Moderation is implemented by messages that remove other messages from chat.
+type Moderation interface {
+	Affects(msg Message) bool
+}
+
+type baseMessageGetter interface {
+	base() *baseMessage
+}
+
+// affectable returns the base of messages that moderation can act on, which is
+// every message that is not a moderation message itself.
+func affectable(msg Message) (*baseMessage, bool) {
+	if _, ok := msg.(Moderation); ok {
+		return nil, false
+	}
+	getter, ok := msg.(baseMessageGetter)
+	if !ok {
+		return nil, false
+	}
+	return getter.base(), true
+}
+
+func parseClearChatMessage(message *twitch.ClearChatMessage) Message {
+	base := baseMessage{
+		Time:    message.Time,
+		Channel: message.Channel,
+		RoomID:  message.RoomID,
+	}
+
+	switch {
+	case message.TargetUserID == "" && message.TargetUsername == "":
+		return &clearChatMessage{baseMessage: base}
+	case message.BanDuration > 0:
+		return &timeoutMessage{
+			baseMessage:    base,
+			TargetUserID:   message.TargetUserID,
+			TargetUsername: message.TargetUsername,
+			Duration:       time.Duration(message.BanDuration) * time.Second,
+		}
+	default:
+		return &banMessage{
+			baseMessage:    base,
+			TargetUserID:   message.TargetUserID,
+			TargetUsername: message.TargetUsername,
+		}
+	}
+}
+
+func parseClearMessage(message *twitch.ClearMessage) Message {
+	// go-twitch-irc does not parse the timestamp of CLEARMSG.
+	sentAt := time.Now()
+	if millis, err := strconv.ParseInt(message.Tags["tmi-sent-ts"], 10, 64); err == nil {
+		sentAt = time.UnixMilli(millis)
+	}
+
+	return &deleteMessage{
+		baseMessage: baseMessage{
+			Time:    sentAt,
+			Channel: message.Channel,
+			RoomID:  message.Tags["room-id"],
+		},
+		TargetMessageID: message.TargetMsgID,
+		TargetUsername:  message.Login,
+		Message:         message.Message,
+	}
+}
+
+func renderModeration(m *baseMessage, options RenderOptions, style lipgloss.Style, text string) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderTime(options, builder)
+	builder.WriteStringWithStyle(text, moderationStyle)
+	return builder.String()
+}
+
+type clearChatMessage struct {
+	baseMessage
+}
+
+func (m *clearChatMessage) Affects(msg Message) bool {
+	base, ok := affectable(msg)
+	return ok && !base.Time.After(m.Time)
+}
+
+func (m *clearChatMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	return renderModeration(&m.baseMessage, options, style, "Chat was cleared by a moderator")
+}
+
+type timeoutMessage struct {
+	baseMessage
+	TargetUserID   string
+	TargetUsername string
+	Duration       time.Duration // ban-duration
+}
+
+func (m *timeoutMessage) Affects(msg Message) bool {
+	base, ok := affectable(msg)
+	return ok && base.User.ID == m.TargetUserID && !base.Time.After(m.Time)
+}
+
+func (m *timeoutMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	return renderModeration(&m.baseMessage, options, style, fmt.Sprintf("%s has been timed out for %s", m.TargetUsername, m.Duration))
+}
+
+type banMessage struct {
+	baseMessage
+	TargetUserID   string
+	TargetUsername string
+}
+
+func (m *banMessage) Affects(msg Message) bool {
+	base, ok := affectable(msg)
+	return ok && base.User.ID == m.TargetUserID && !base.Time.After(m.Time)
+}
+
+func (m *banMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	return renderModeration(&m.baseMessage, options, style, fmt.Sprintf("%s has been permanently banned", m.TargetUsername))
+}
+
+type deleteMessage struct {
+	baseMessage
+	TargetMessageID string // target-msg-id
+	TargetUsername  string // login
+	Message         string
+}
+
+func (m *deleteMessage) Affects(msg Message) bool {
+	base, ok := affectable(msg)
+	return ok && base.ID != "" && base.ID == m.TargetMessageID
+}
+
+func (m *deleteMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	return renderModeration(&m.baseMessage, options, style, fmt.Sprintf("A message from %s was deleted", m.TargetUsername))
+}
@@ -0,0 +1,36 @@
+package message
+
+import (
+	"fmt"
+	"testing"
+)
+
+func chatAt(seconds int) Message {
+	return ParseRaw(fmt.Sprintf("@display-name=Viewer;id=msg-%[1]d;tmi-sent-ts=%[2]d;user-id=42;room-id=1 :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #channel :message %[1]d",
+		seconds, 1700000000000+int64(seconds)*1000))
+}
+
+func TestModerationOnlyAffectsEarlierMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"timeout", "@ban-duration=10;room-id=1;target-user-id=42;tmi-sent-ts=1700000002000 :tmi.twitch.tv CLEARCHAT #channel :viewer"},
+		{"ban", "@room-id=1;target-user-id=42;tmi-sent-ts=1700000002000 :tmi.twitch.tv CLEARCHAT #channel :viewer"},
+		{"clear", "@room-id=1;tmi-sent-ts=1700000002000 :tmi.twitch.tv CLEARCHAT #channel"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			moderation, ok := ParseRaw(test.raw).(Moderation)
+			if !ok {
+				t.Fatalf("%s is not a moderation message", test.raw)
+			}
+			if !moderation.Affects(chatAt(1)) {
+				t.Error("a message sent before does not get removed")
+			}
+			if moderation.Affects(chatAt(900)) {
+				t.Error("a message sent long after gets removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package message
+
+import (
+	"strings"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/emotes"
+	"github.com/nextthang/lurkmode/internal/stylebuilder"
+)
+
+const replySnippetLength = 50
+
+// Reply is implemented by messages that can be a reply to another message.
+// ParentID is empty if the message is not a reply.
+type Reply interface {
+	MessageID() string
+	ParentID() string
+}
+
+func (m *baseMessage) MessageID() string {
+	return m.ID
+}
+
+func (m *channelMessage) ParentID() string {
+	if m.Reply == nil {
+		return ""
+	}
+	return m.Reply.ParentMsgID
+}
+
+// stripReplyMention removes the @name Twitch puts in front of every reply,
+// moving the emotes along with the text.
+func (m *channelMessage) stripReplyMention() {
+	if m.Reply == nil {
+		return
+	}
+
+	var mention string
+	for _, name := range []string{m.Reply.ParentDisplayName, m.Reply.ParentUserLogin} {
+		prefix := "@" + name + " "
+		if name != "" && len(m.Message) >= len(prefix) && strings.EqualFold(m.Message[:len(prefix)], prefix) {
+			mention = m.Message[:len(prefix)]
+			break
+		}
+	}
+	if mention == "" {
+		return
+	}
+
+	offset := len([]rune(mention))
+	shifted := make([]emotes.Occurrence, 0, len(m.Emotes))
+	for _, occurrence := range m.Emotes {
+		if occurrence.Start < offset {
+			continue
+		}
+		occurrence.Start -= offset
+		occurrence.End -= offset
+		shifted = append(shifted, occurrence)
+	}
+	m.Message = m.Message[len(mention):]
+	m.Emotes = shifted
+}
+
+func replySnippet(text string) string {
+	runes := []rune(text)
+	if len(runes) <= replySnippetLength {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:replySnippetLength-1])) + "…"
+}
+
+func renderReplyName(reply *twitch.Reply) string {
+	if reply.ParentDisplayName != "" {
+		return reply.ParentDisplayName
+	}
+	return reply.ParentUserLogin
+}
+
+func (m *channelMessage) renderReply(options RenderOptions, builder *stylebuilder.StyleBuilder) {
+	if m.Reply == nil {
+		return
+	}
+	if options.Plain {
+		builder.WriteStringWithStyle("(↪ @"+renderReplyName(m.Reply)+") ", replyStyle)
+		return
+	}
+	builder.WriteStringWithStyle("↪ replying to @"+renderReplyName(m.Reply)+": "+replySnippet(m.Reply.ParentMsgBody), replyStyle)
+	builder.WriteStyledString("\n")
+}
@@ -0,0 +1,41 @@
+package message
+
+import (
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/nextthang/lurkmode/internal/theme"
+)
+
+var (
+	timeStyle             lipgloss.Style
+	deletedStyle          lipgloss.Style
+	moderationStyle       lipgloss.Style
+	replyStyle            lipgloss.Style
+	broadcasterBadgeStyle lipgloss.Style
+	vipBadgeStyle         lipgloss.Style
+	modBadgeStyle         lipgloss.Style
+	subBadgeStyle         lipgloss.Style
+	highlightStyle        lipgloss.Style
+	userColors            bool
+)
+
+func init() {
+	SetTheme(theme.Default())
+}
+
+// SetTheme changes the colours messages are rendered in from then on.
+func SetTheme(t theme.Theme) {
+	timeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Timestamp))
+	deletedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Dimmed)).Italic(true)
+	moderationStyle = deletedStyle
+	replyStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Dimmed))
+	broadcasterBadgeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Badges.Broadcaster))
+	vipBadgeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Badges.VIP))
+	modBadgeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Badges.Moderator))
+	subBadgeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Badges.Subscriber))
+	highlightStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.HighlightText)).Background(lipgloss.Color(t.Highlight))
+	if t.Highlight == "" {
+		highlightStyle = lipgloss.NewStyle().Reverse(true)
+	}
+	userColors = t.UserColors
+	setBackground(t.Background)
+}
@@ -0,0 +1,147 @@
+package message
+
+import (
+	"math"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// minContrast is the WCAG AA contrast ratio for normal text.
+const minContrast = 4.5
+
+// maxCachedColors bounds the adjusted colours we remember, as every chatter
+// can pick their own.
+const maxCachedColors = 4096
+
+// defaultColors are the colours Twitch gives users who never picked one.
+var defaultColors = []string{
+	"#FF0000", "#0000FF", "#008000", "#B22222", "#FF7F50",
+	"#9ACD32", "#FF4500", "#2E8B57", "#DAA520", "#D2691E",
+	"#5F9EA0", "#1E90FF", "#FF69B4", "#8A2BE2", "#00FF7F",
+}
+
+var (
+	white = colorful.Color{R: 1, G: 1, B: 1}
+	black = colorful.Color{}
+)
+
+var (
+	background    colorful.Color
+	hasBackground bool
+	readable      = map[string]string{}
+)
+
+func setBackground(hex string) {
+	background, hasBackground = colorful.Color{}, false
+	if c, err := colorful.Hex(hex); err == nil {
+		background, hasBackground = c, true
+	}
+	clear(readable)
+}
+
+// userColor is the colour a user's name is shown in: the one they picked or
+// the one Twitch would pick for them, made readable on the background.
+func userColor(user twitch.User) string {
+	color := user.Color
+	if color == "" {
+		color = defaultColor(user.Name)
+	}
+	if color == "" || !hasBackground {
+		return color
+	}
+
+	if adjusted, ok := readable[color]; ok {
+		return adjusted
+	}
+	adjusted := color
+	if c, err := colorful.Hex(color); err == nil {
+		adjusted = readableOn(c, background).Hex()
+	}
+	if len(readable) >= maxCachedColors {
+		clear(readable)
+	}
+	readable[color] = adjusted
+	return adjusted
+}
+
+// defaultColor picks from the default colours by the first and last letter
+// of the login, the same way the Twitch web client does.
+func defaultColor(login string) string {
+	if login == "" {
+		return ""
+	}
+	sum := int(login[0]) + int(login[len(login)-1])
+	return defaultColors[sum%len(defaultColors)]
+}
+
+// readableOn changes the lightness of c as little as possible for it to reach
+// the minimum contrast on the background, keeping its hue and chroma.
+// Colours too saturated to get light or dark enough are faded toward white or
+// black instead, which still keeps their hue.
+func readableOn(c, background colorful.Color) colorful.Color {
+	if contrast(c, background) >= minContrast {
+		return c
+	}
+
+	// Head for whichever of white and black stands out more. On mid-tones
+	// that is not the opposite of what the background looks like: from a
+	// luminance of about 0.18 on, only black reaches the minimum contrast.
+	extreme, target := white, 1.0
+	if contrast(black, background) > contrast(white, background) {
+		extreme, target = black, 0
+	}
+
+	h, chroma, l := c.Hcl()
+	if adjusted, ok := closestReadable(background, l, target, func(l float64) colorful.Color {
+		return hcl(h, chroma, l)
+	}); ok {
+		return adjusted
+	}
+	adjusted, _ := closestReadable(background, 0, 1, func(t float64) colorful.Color {
+		return rounded(c.BlendLab(extreme, t))
+	})
+	return adjusted
+}
+
+// closestReadable searches from start to end for the first colour that
+// reaches the minimum contrast on the background. Once the contrast is
+// enough on the way, it only grows from there. It reports false, with the
+// colour at end, if even that is not enough.
+func closestReadable(background colorful.Color, start, end float64, color func(float64) colorful.Color) (colorful.Color, bool) {
+	if contrast(color(end), background) < minContrast {
+		return color(end), false
+	}
+	low, high := start, end
+	for range 20 {
+		middle := (low + high) / 2
+		if contrast(color(middle), background) >= minContrast {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return color(high), true
+}
+
+func hcl(h, c, l float64) colorful.Color {
+	return rounded(colorful.Hcl(h, c, l))
+}
+
+// rounded returns the colour as it will be written out, so that rounding to
+// hex can't push it back under the minimum contrast.
+func rounded(c colorful.Color) colorful.Color {
+	result, _ := colorful.Hex(c.Clamped().Hex())
+	return result
+}
+
+// luminance is the relative luminance as defined by WCAG.
+func luminance(c colorful.Color) float64 {
+	r, g, b := c.Clamped().LinearRgb()
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+func contrast(a, b colorful.Color) float64 {
+	la, lb := luminance(a), luminance(b)
+	return (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+}
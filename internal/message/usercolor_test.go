@@ -0,0 +1,35 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+func TestReadableOn(t *testing.T) {
+	// Mid-tones around a luminance of 0.18 only reach the minimum contrast
+	// with one of white and black.
+	for _, backgroundHex := range []string{"#0e0e10", "#ffffff", "#000000", "#595959", "#767676", "#808080", "#9a9a9a", "#3a6ea5"} {
+		bg, err := colorful.Hex(backgroundHex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, colorHex := range append(defaultColors, "#808080", "#7f7f80") {
+			c, err := colorful.Hex(colorHex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := contrast(readableOn(c, bg), bg); got < minContrast {
+				t.Errorf("%s on %s: got contrast %.2f, want at least %.1f", colorHex, backgroundHex, got, minContrast)
+			}
+		}
+	}
+}
+
+func TestReadableOnKeepsReadableColors(t *testing.T) {
+	bg, _ := colorful.Hex("#0e0e10")
+	c, _ := colorful.Hex("#1E90FF")
+	if got := readableOn(c, bg); got != c {
+		t.Errorf("got %s, want %s unchanged", got.Hex(), c.Hex())
+	}
+}
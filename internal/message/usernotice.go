@@ -0,0 +1,343 @@
+package message
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/stylebuilder"
+)
+
+var announcementColors = map[string]lipgloss.Style{
+	"PRIMARY": lipgloss.NewStyle().Foreground(lipgloss.Color("#9146ff")),
+	"BLUE":    lipgloss.NewStyle().Foreground(lipgloss.Color("#00d6d6")),
+	"GREEN":   lipgloss.NewStyle().Foreground(lipgloss.Color("#00db84")),
+	"ORANGE":  lipgloss.NewStyle().Foreground(lipgloss.Color("#ffb31a")),
+	"PURPLE":  lipgloss.NewStyle().Foreground(lipgloss.Color("#ff75e6")),
+}
+
+// renderWithMessage appends the optional message a user attached to a notice
+// on its own line.
+func renderWithMessage(builder *stylebuilder.StyleBuilder, m *channelMessage, options RenderOptions, style lipgloss.Style) string {
+	if m.Message != "" {
+		writeLineBreak(options, builder)
+		builder.WriteStyledString(m.Render(options, style))
+	}
+	return builder.String()
+}
+
+func parseAnnouncementMessage(message *twitch.UserNoticeMessage) Message {
+	return &announcementMessage{
+		channelMessage: newChannelMessageFromNotice(message),
+		Color:          parseMsgParamsKeyString(message, "msg-param-color", "PRIMARY"),
+	}
+}
+
+type announcementMessage struct {
+	channelMessage
+	Color string // msg-param-color
+}
+
+func (m *announcementMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	colorStyle, ok := announcementColors[m.Color]
+	if !ok {
+		colorStyle = announcementColors["PRIMARY"]
+	}
+
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderTime(options, builder)
+	builder.WriteStringWithStyle("[📢 Announcement] ", colorStyle.Bold(true))
+	builder.WriteStyledString(renderUserTags(m.User, style))
+	builder.WriteStyledString(renderColoredName(m.User, style))
+	builder.WriteString(": ")
+	m.renderText(options, builder)
+	return builder.String()
+}
+
+func (m *announcementMessage) isUserNotice() {}
+
+func parseBitsBadgeTierMessage(message *twitch.UserNoticeMessage) Message {
+	return &bitsBadgeTierMessage{
+		channelMessage: newChannelMessageFromNotice(message),
+		Threshold:      parseMsgParamsKeyUint(message, "msg-param-threshold", 0),
+	}
+}
+
+type bitsBadgeTierMessage struct {
+	channelMessage
+	Threshold uint32 // msg-param-threshold
+}
+
+func (m *bitsBadgeTierMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(fmt.Sprintf(" just earned a new %d Bits badge!", m.Threshold))
+	return renderWithMessage(builder, &m.channelMessage, options, style)
+}
+
+func (m *bitsBadgeTierMessage) isUserNotice() {}
+
+func parseGiftPaidUpgradeMessage(message *twitch.UserNoticeMessage) Message {
+	return &giftPaidUpgradeMessage{
+		baseMessage: newBaseMessageFromNotice(message),
+		Gifter:      parseMsgParamsKeyString(message, "msg-param-sender-name", ""),
+	}
+}
+
+func parseAnonGiftPaidUpgradeMessage(message *twitch.UserNoticeMessage) Message {
+	return &giftPaidUpgradeMessage{
+		baseMessage: newBaseMessageFromNotice(message),
+	}
+}
+
+type giftPaidUpgradeMessage struct {
+	baseMessage
+	Gifter string // msg-param-sender-name, empty for anonymous gifts
+}
+
+func (m *giftPaidUpgradeMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	gifter := m.Gifter
+	if gifter == "" {
+		gifter = "an anonymous user"
+	}
+
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(fmt.Sprintf(" is continuing the Gift Sub they got from %s!", gifter))
+	return builder.String()
+}
+
+func (m *giftPaidUpgradeMessage) isUserNotice() {}
+
+func parsePrimePaidUpgradeMessage(message *twitch.UserNoticeMessage) Message {
+	return &primePaidUpgradeMessage{
+		baseMessage: newBaseMessageFromNotice(message),
+		Plan:        parseSubPlan(message.MsgParams["msg-param-sub-plan"]),
+	}
+}
+
+type primePaidUpgradeMessage struct {
+	baseMessage
+	Plan SubPlan // msg-param-sub-plan
+}
+
+func (m *primePaidUpgradeMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(fmt.Sprintf(" converted from a Prime sub to a Tier %d sub!", m.Plan))
+	return builder.String()
+}
+
+func (m *primePaidUpgradeMessage) isUserNotice() {}
+
+func parsePayForwardMessage(message *twitch.UserNoticeMessage) Message {
+	return &payForwardMessage{
+		baseMessage:    newBaseMessageFromNotice(message),
+		PriorGifter:    parseMsgParamsKeyString(message, "msg-param-prior-gifter-display-name", ""),
+		Recipient:      parseMsgParamsKeyString(message, "msg-param-recipient-display-name", ""),
+		PriorAnonymous: parseMsgParamsKeyString(message, "msg-param-prior-gifter-anonymous", "") == "true",
+	}
+}
+
+type payForwardMessage struct {
+	baseMessage
+	PriorGifter    string // msg-param-prior-gifter-display-name
+	PriorAnonymous bool   // msg-param-prior-gifter-anonymous
+	Recipient      string // msg-param-recipient-display-name, empty when paying forward to the community
+}
+
+func (m *payForwardMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	gifter := m.PriorGifter
+	if m.PriorAnonymous || gifter == "" {
+		gifter = "an anonymous user"
+	}
+	recipient := "the community"
+	if m.Recipient != "" {
+		recipient = m.Recipient
+	}
+
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(fmt.Sprintf(" is paying forward the Gift they got from %s to %s!", gifter, recipient))
+	return builder.String()
+}
+
+func (m *payForwardMessage) isUserNotice() {}
+
+func parseRewardGiftMessage(message *twitch.UserNoticeMessage) Message {
+	return &rewardGiftMessage{
+		baseMessage:   newBaseMessageFromNotice(message),
+		SelectedCount: parseMsgParamsKeyUint(message, "msg-param-selected-count", 0),
+	}
+}
+
+type rewardGiftMessage struct {
+	baseMessage
+	SelectedCount uint32 // msg-param-selected-count
+}
+
+func (m *rewardGiftMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(fmt.Sprintf("'s gift shared rewards with %d others in chat!", m.SelectedCount))
+	return builder.String()
+}
+
+func (m *rewardGiftMessage) isUserNotice() {}
+
+func parseUnraidMessage(message *twitch.UserNoticeMessage) Message {
+	return &unraidMessage{
+		baseMessage: newBaseMessageFromNotice(message),
+	}
+}
+
+type unraidMessage struct {
+	baseMessage
+}
+
+func (m *unraidMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderTime(options, builder)
+	builder.WriteString("The raid has been cancelled.")
+	return builder.String()
+}
+
+func (m *unraidMessage) isUserNotice() {}
+
+func parseRitualMessage(message *twitch.UserNoticeMessage) Message {
+	return &ritualMessage{
+		channelMessage: newChannelMessageFromNotice(message),
+		Ritual:         parseMsgParamsKeyString(message, "msg-param-ritual-name", ""),
+		SystemMsg:      message.SystemMsg,
+	}
+}
+
+type ritualMessage struct {
+	channelMessage
+	Ritual    string // msg-param-ritual-name
+	SystemMsg string
+}
+
+func (m *ritualMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	switch m.Ritual {
+	case "new_chatter":
+		m.renderHeader(options, style, builder)
+		builder.WriteString(" is new to chat! Say hello!")
+	default:
+		m.renderTime(options, builder)
+		builder.WriteString(m.SystemMsg)
+	}
+	return renderWithMessage(builder, &m.channelMessage, options, style)
+}
+
+func (m *ritualMessage) isUserNotice() {}
+
+func parseViewerMilestoneMessage(message *twitch.UserNoticeMessage) Message {
+	return &viewerMilestoneMessage{
+		channelMessage: newChannelMessageFromNotice(message),
+		Category:       parseMsgParamsKeyString(message, "msg-param-category", ""),
+		Value:          parseMsgParamsKeyUint(message, "msg-param-value", 0),
+		SystemMsg:      message.SystemMsg,
+	}
+}
+
+type viewerMilestoneMessage struct {
+	channelMessage
+	Category  string // msg-param-category
+	Value     uint32 // msg-param-value
+	SystemMsg string
+}
+
+func (m *viewerMilestoneMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	switch m.Category {
+	case "watch-streak":
+		m.renderHeader(options, style, builder)
+		builder.WriteString(fmt.Sprintf(" watched %d consecutive streams!", m.Value))
+	default:
+		m.renderTime(options, builder)
+		builder.WriteString(m.SystemMsg)
+	}
+	return renderWithMessage(builder, &m.channelMessage, options, style)
+}
+
+func (m *viewerMilestoneMessage) isUserNotice() {}
+
+func parseCharityDonationMessage(message *twitch.UserNoticeMessage) Message {
+	return &charityDonationMessage{
+		baseMessage: newBaseMessageFromNotice(message),
+		Charity:     parseMsgParamsKeyString(message, "msg-param-charity-name", ""),
+		Amount:      parseMsgParamsKeyUint(message, "msg-param-donation-amount", 0),
+		Exponent:    parseMsgParamsKeyUint(message, "msg-param-exponent", 0),
+		Currency:    parseMsgParamsKeyString(message, "msg-param-donation-currency", ""),
+	}
+}
+
+type charityDonationMessage struct {
+	baseMessage
+	Charity  string // msg-param-charity-name
+	Amount   uint32 // msg-param-donation-amount
+	Exponent uint32 // msg-param-exponent
+	Currency string // msg-param-donation-currency
+}
+
+func (m *charityDonationMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	amount := float64(m.Amount) / math.Pow10(int(m.Exponent))
+
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderHeader(options, style, builder)
+	builder.WriteString(fmt.Sprintf(" donated %.*f %s to %s!", m.Exponent, amount, m.Currency, m.Charity))
+	return builder.String()
+}
+
+func (m *charityDonationMessage) isUserNotice() {}
+
+// parseSharedChatNoticeMessage unwraps notices that happened in another
+// channel of a shared chat session. They carry the original msg-id in
+// msg-param-source-msg-id.
+func parseSharedChatNoticeMessage(message *twitch.UserNoticeMessage) Message {
+	sourceMsgID := parseMsgParamsKeyString(message, "msg-param-source-msg-id", "")
+	if sourceMsgID == "" || sourceMsgID == message.MsgID {
+		return parseSystemNoticeMessage(message)
+	}
+
+	source := *message
+	source.MsgID = sourceMsgID
+	source.MsgParams = maps.Clone(message.MsgParams)
+	for key, value := range message.MsgParams {
+		if sourceKey, ok := strings.CutPrefix(key, "msg-param-source-"); ok {
+			source.MsgParams["msg-param-"+sourceKey] = value
+		}
+	}
+
+	return parseUserNoticeMessage(&source)
+}
+
+func parseSystemNoticeMessage(message *twitch.UserNoticeMessage) Message {
+	return &systemNoticeMessage{
+		channelMessage: newChannelMessageFromNotice(message),
+		MsgID:          message.MsgID,
+		SystemMsg:      message.SystemMsg,
+	}
+}
+
+// systemNoticeMessage is used for every notice we don't know better how to
+// render, by showing the text Twitch itself provides.
+type systemNoticeMessage struct {
+	channelMessage
+	MsgID     string
+	SystemMsg string // system-msg
+}
+
+func (m *systemNoticeMessage) Render(options RenderOptions, style lipgloss.Style) string {
+	builder := stylebuilder.NewStyleBuilder(style)
+	m.renderTime(options, builder)
+	builder.WriteString(m.SystemMsg)
+	return renderWithMessage(builder, &m.channelMessage, options, style)
+}
+
+func (m *systemNoticeMessage) isUserNotice() {}
@@ -0,0 +1,120 @@
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// userNotice builds the raw USERNOTICE line Twitch sends for a msg-id, from
+// Viewer in #lurkmode.
+func userNotice(msgID string, params map[string]string, text string) string {
+	tags := []string{
+		"badges=",
+		"color=#1E90FF",
+		"display-name=Viewer",
+		"id=notice-1",
+		"login=viewer",
+		"msg-id=" + msgID,
+		"room-id=1",
+		`system-msg=Something\shappened.`,
+		"tmi-sent-ts=1700000000000",
+		"user-id=42",
+	}
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		tags = append(tags, fmt.Sprintf("msg-param-%s=%s", name, params[name]))
+	}
+	line := fmt.Sprintf("@%s :tmi.twitch.tv USERNOTICE #lurkmode", strings.Join(tags, ";"))
+	if text != "" {
+		line += " :" + text
+	}
+	return line
+}
+
+func TestParseUserNotice(t *testing.T) {
+	tests := []struct {
+		msgID  string
+		params map[string]string
+		text   string
+		kind   Kind
+		want   string
+	}{
+		{"sub", map[string]string{"sub-plan": "1000"}, "", KindSub, "Viewer subscribed at Tier 1"},
+		{"sub", map[string]string{"sub-plan": "Prime"}, "hi", KindSub, "Viewer subscribed with Prime"},
+		{"resub", map[string]string{"sub-plan": "2000", "cumulative-months": "12", "streak-months": "3"}, "still here", KindSub,
+			"Viewer resubscribed at Tier 2! They have been subscribed for 12 months! Their current streak is 3 months!"},
+		{"subgift", map[string]string{"sub-plan": "1000", "recipient-display-name": "Lucky", "recipient-user-name": "lucky", "recipient-id": "43"}, "", KindGift,
+			"Viewer gifted a Tier 1 subscription to Lucky!"},
+		{"anonsubgift", map[string]string{"sub-plan": "3000", "recipient-display-name": "Lucky"}, "", KindGift, "gifted a Tier 3 subscription to Lucky!"},
+		{"submysterygift", map[string]string{"sub-plan": "1000", "mass-gift-count": "5", "sender-count": "50"}, "", KindGift,
+			"Viewer gifted 5 Tier 1 subscriptions! Total gifted subscriptions: 50"},
+		{"anonsubmysterygift", map[string]string{"sub-plan": "1000", "mass-gift-count": "10"}, "", KindGift, "gifted 10 Tier 1 subscriptions!"},
+		{"giftpaidupgrade", map[string]string{"sender-name": "Gifter"}, "", KindSub, "Viewer is continuing the Gift Sub they got from Gifter!"},
+		{"anongiftpaidupgrade", nil, "", KindSub, "Viewer is continuing the Gift Sub they got from an anonymous user!"},
+		{"primepaidupgrade", map[string]string{"sub-plan": "1000"}, "", KindSub, "Viewer converted from a Prime sub to a Tier 1 sub!"},
+		{"communitypayforward", map[string]string{"prior-gifter-display-name": "Gifter", "prior-gifter-anonymous": "false"}, "", KindGift,
+			"Viewer is paying forward the Gift they got from Gifter to the community!"},
+		{"standardpayforward", map[string]string{"prior-gifter-anonymous": "true", "recipient-display-name": "Lucky"}, "", KindGift,
+			"Viewer is paying forward the Gift they got from an anonymous user to Lucky!"},
+		{"rewardgift", map[string]string{"selected-count": "5"}, "", KindGift, "Viewer's gift shared rewards with 5 others in chat!"},
+		{"raid", map[string]string{"viewerCount": "1234"}, "", KindRaid, "Viewer raided with 1234 viewers!"},
+		{"unraid", nil, "", KindRaid, "The raid has been cancelled."},
+		{"ritual", map[string]string{"ritual-name": "new_chatter"}, "HeyGuys", KindNotice, "Viewer is new to chat! Say hello!"},
+		{"bitsbadgetier", map[string]string{"threshold": "1000"}, "", KindNotice, "Viewer just earned a new 1000 Bits badge!"},
+		{"announcement", map[string]string{"color": "BLUE"}, "stream starts soon", KindAnnouncement, "[📢 Announcement] Viewer: stream starts soon"},
+		{"viewermilestone", map[string]string{"category": "watch-streak", "value": "10"}, "", KindNotice, "Viewer watched 10 consecutive streams!"},
+		{"charitydonation", map[string]string{"charity-name": "Direct\\sRelief", "donation-amount": "1050", "exponent": "2", "donation-currency": "USD"}, "", KindNotice,
+			"Viewer donated 10.50 USD to Direct Relief!"},
+		// Shared chat notices are shown like the notice they wrap.
+		{"sharedchatnotice", map[string]string{"source-msg-id": "sub", "source-sub-plan": "2000"}, "", KindSub, "Viewer subscribed at Tier 2"},
+		{"sharedchatnotice", map[string]string{"source-msg-id": "raid", "source-viewerCount": "7"}, "", KindRaid, "Viewer raided with 7 viewers!"},
+		// Notices without a parser show the system message Twitch provides.
+		{"sharedchatnotice", nil, "", KindNotice, "Something happened."},
+		{"somethingnew", nil, "", KindNotice, "Something happened."},
+	}
+	for _, test := range tests {
+		t.Run(test.msgID, func(t *testing.T) {
+			msg := ParseRaw(userNotice(test.msgID, test.params, test.text))
+			if msg == nil {
+				t.Fatal("the notice was not parsed")
+			}
+			if _, ok := msg.(UserNotice); !ok {
+				t.Errorf("%T is not a user notice", msg)
+			}
+			if msg.Kind() != test.kind {
+				t.Errorf("got kind %v, want %v", msg.Kind(), test.kind)
+			}
+			if msg.ChannelName() != "lurkmode" {
+				t.Errorf("got channel %q, want lurkmode", msg.ChannelName())
+			}
+			rendered := ansi.Strip(msg.Render(RenderOptions{Plain: true}, lipgloss.NewStyle()))
+			if !strings.Contains(rendered, test.want) {
+				t.Errorf("got %q, want it to contain %q", rendered, test.want)
+			}
+			if test.text != "" && !strings.Contains(rendered, test.text) {
+				t.Errorf("got %q, want it to contain the message %q", rendered, test.text)
+			}
+		})
+	}
+}
+
+func TestUnknownUserNoticeIsLoggedOnce(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	for range 3 {
+		ParseRaw(userNotice("loggedonce", nil, ""))
+	}
+	if got := strings.Count(logged.String(), "loggedonce"); got != 1 {
+		t.Errorf("logged the unknown notice %d times, want once", got)
+	}
+}
@@ -0,0 +1,220 @@
+// Package replay plays back recorded chat instead of connecting to Twitch.
+//
+// Recordings are raw IRC lines, as written by the irc chat log format, or the
+// JSON Lines chat logs, of which the raw line of every record is used.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+var (
+	ErrNoMessages = errors.New("recording contains no chat messages")
+	ErrReadOnly   = errors.New("sending messages is not possible in a replay")
+)
+
+// maxLineSize allows for the longest lines Twitch sends, with a lot of tags.
+const maxLineSize = 1024 * 1024
+
+// Load parses a recording into messages, skipping lines that are not chat
+// messages such as PINGs or JOINs.
+func Load(r io.Reader) ([]message.Message, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var messages []message.Message
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "{") {
+			var record struct {
+				Raw string `json:"raw"`
+			}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			line = record.Raw
+		}
+		if line == "" {
+			continue
+		}
+		if msg := message.NewMessage(twitch.ParseMessage(line)); msg != nil {
+			messages = append(messages, msg)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNoMessages
+	}
+	return messages, nil
+}
+
+func LoadFile(path string) ([]message.Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	messages, err := Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return messages, nil
+}
+
+// Channels returns the channels of the messages in the order they first appear.
+func Channels(messages []message.Message) []string {
+	var channels []string
+	for _, msg := range messages {
+		if !slices.Contains(channels, msg.ChannelName()) {
+			channels = append(channels, msg.ChannelName())
+		}
+	}
+	return channels
+}
+
+type Options struct {
+	// Speed scales the time between messages, 2 plays twice as fast as the
+	// recording. Zero or less plays everything at once.
+	Speed float64
+	// Step waits for Step to be called before every message.
+	Step bool
+	// Finish returns from Connect at the end of the recording instead of
+	// waiting for Disconnect.
+	Finish bool
+}
+
+// Player feeds recorded messages to the app in place of a Twitch connection.
+type Player struct {
+	messages []message.Message
+	queue    *queue.Queue[message.Message]
+	options  Options
+	stepped  chan struct{}
+	stop     chan struct{}
+	once     sync.Once
+
+	mutex sync.Mutex
+	steps int
+}
+
+func NewPlayer(messageQueue *queue.Queue[message.Message], messages []message.Message, options Options) *Player {
+	return &Player{
+		messages: messages,
+		queue:    messageQueue,
+		options:  options,
+		stepped:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Connect plays the recording and blocks until Disconnect is called, like
+// the Twitch client would.
+func (p *Player) Connect() error {
+	defer p.queue.Close()
+
+	var previous time.Time
+	for _, msg := range p.messages {
+		if !p.wait(previous, msg.SentAt()) {
+			return nil
+		}
+		previous = msg.SentAt()
+
+		// Unlike Twitch, a recording can wait for the UI to catch up.
+		if !p.queue.PushWait(msg, p.stop) {
+			return nil
+		}
+	}
+
+	if !p.options.Finish {
+		<-p.stop
+	}
+	return nil
+}
+
+// wait returns once it is time for the next message, or false if the replay
+// was stopped in the meantime.
+func (p *Player) wait(previous, next time.Time) bool {
+	if p.options.Step {
+		for {
+			p.mutex.Lock()
+			if p.steps > 0 {
+				p.steps--
+				p.mutex.Unlock()
+				return true
+			}
+			p.mutex.Unlock()
+
+			select {
+			case <-p.stepped:
+			case <-p.stop:
+				return false
+			}
+		}
+	}
+
+	if p.options.Speed <= 0 || previous.IsZero() || next.IsZero() || !next.After(previous) {
+		return true
+	}
+	timer := time.NewTimer(time.Duration(float64(next.Sub(previous)) / p.options.Speed))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-p.stop:
+		return false
+	}
+}
+
+func (p *Player) Stepping() bool {
+	return p.options.Step
+}
+
+// Step plays the next message when stepping through the recording.
+func (p *Player) Step() {
+	if !p.options.Step {
+		return
+	}
+	p.mutex.Lock()
+	p.steps++
+	p.mutex.Unlock()
+
+	select {
+	case p.stepped <- struct{}{}:
+	default:
+		// Connect is woken up already.
+	}
+}
+
+func (p *Player) Disconnect() error {
+	p.once.Do(func() { close(p.stop) })
+	return nil
+}
+
+func (p *Player) Authenticated() bool {
+	return false
+}
+
+func (p *Player) Say(channel, text string) (message.Message, error) {
+	return nil, ErrReadOnly
+}
+
+// AddChannel and RemoveChannel do nothing, the recording is played as is and
+// the messages of channels without a tab are dropped by the app.
+func (p *Player) AddChannel(channel string) {}
+
+func (p *Player) RemoveChannel(channel string) {}
@@ -0,0 +1,194 @@
+package replay
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+func kinds(messages []message.Message) []message.Kind {
+	result := make([]message.Kind, len(messages))
+	for i, msg := range messages {
+		result[i] = msg.Kind()
+	}
+	return result
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		kinds    []message.Kind
+		channels []string
+	}{
+		{
+			// The welcome, PING and JOIN lines are skipped.
+			path: "testdata/chat.irc",
+			kinds: []message.Kind{
+				message.KindChat,
+				message.KindChat,
+				message.KindSub,
+				message.KindChat,
+				message.KindModeration,
+			},
+			channels: []string{"lurkmode", "otherchannel"},
+		},
+		{
+			// Records without a raw line can't be played back.
+			path:     "testdata/chat.jsonl",
+			kinds:    []message.Kind{message.KindChat, message.KindChat},
+			channels: []string{"lurkmode"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			messages, err := LoadFile(test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := kinds(messages); !slices.Equal(got, test.kinds) {
+				t.Errorf("got kinds %v, want %v", got, test.kinds)
+			}
+			if got := Channels(messages); !slices.Equal(got, test.channels) {
+				t.Errorf("got channels %v, want %v", got, test.channels)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+		want      string
+	}{
+		{"empty", "", ErrNoMessages.Error()},
+		{"no chat", "PING :tmi.twitch.tv\n:tmi.twitch.tv 001 justinfan123 :Welcome, GLHF!\n", ErrNoMessages.Error()},
+		{"broken JSON", "PING :tmi.twitch.tv\n{\"raw\": \n", "line 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load(strings.NewReader(test.recording))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func loadFixture(t *testing.T) []message.Message {
+	t.Helper()
+
+	messages, err := LoadFile("testdata/chat.irc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+// play starts playing the messages and returns the queue they arrive in.
+func play(t *testing.T, messages []message.Message, options Options) (*Player, *queue.Queue[message.Message], chan error) {
+	t.Helper()
+
+	messageQueue := queue.New[message.Message](len(messages))
+	player := NewPlayer(messageQueue, messages, options)
+	done := make(chan error, 1)
+	go func() { done <- player.Connect() }()
+	t.Cleanup(func() { player.Disconnect() })
+	return player, messageQueue, done
+}
+
+func TestPlayerSpeed(t *testing.T) {
+	messages := loadFixture(t)
+	// The recording spans 3 s, which takes 30 ms at 100 times the speed.
+	const speed = 100
+	recorded := messages[len(messages)-1].SentAt().Sub(messages[0].SentAt())
+
+	start := time.Now()
+	_, messageQueue, done := play(t, messages, Options{Speed: speed, Finish: true})
+	for i := range messages {
+		msg, ok := messageQueue.Pop()
+		if !ok {
+			t.Fatalf("the queue closed after %d messages", i)
+		}
+		if msg != messages[i] {
+			t.Fatalf("message %d is out of order", i)
+		}
+	}
+	if elapsed, want := time.Since(start), recorded/speed; elapsed < want {
+		t.Errorf("played in %s, want at least %s", elapsed, want)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Connect: %v", err)
+	}
+}
+
+func TestPlayerWaitsForDisconnect(t *testing.T) {
+	messages := loadFixture(t)
+	player, messageQueue, done := play(t, messages, Options{})
+
+	for range messages {
+		messageQueue.Pop()
+	}
+	select {
+	case <-done:
+		t.Fatal("Connect returned before Disconnect")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	player.Disconnect()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Connect did not return after Disconnect")
+	}
+	if _, ok := messageQueue.Pop(); ok {
+		t.Error("the queue is still open")
+	}
+}
+
+func TestPlayerStep(t *testing.T) {
+	messages := loadFixture(t)
+	player, messageQueue, _ := play(t, messages, Options{Step: true})
+	if !player.Stepping() {
+		t.Fatal("the player is not stepping")
+	}
+
+	waitForLen := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for messageQueue.Len() != want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := messageQueue.Len(); got != want {
+			t.Fatalf("got %d messages, want %d", got, want)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	waitForLen(0)
+
+	// Steps taken in quick succession all count.
+	player.Step()
+	player.Step()
+	waitForLen(2)
+	time.Sleep(20 * time.Millisecond)
+	waitForLen(2)
+
+	player.Step()
+	waitForLen(3)
+}
+
+func TestPlayerIsReadOnly(t *testing.T) {
+	player := NewPlayer(queue.New[message.Message](1), nil, Options{})
+	if player.Authenticated() {
+		t.Error("a replay is authenticated")
+	}
+	if _, err := player.Say("lurkmode", "hi"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("got %v, want ErrReadOnly", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package theme holds the colours of the UI. Besides the built-in themes,
+// themes can be loaded from JSON files, which only need to set the colours
+// they change from the theme they are based on:
+//
+//	{
+//	  "base": "light",
+//	  "accent": "#ff7f50",
+//	  "badges": {"moderator": "#008000"}
+//	}
+//
+// Colours are hex codes like "#6441a5", ANSI colour numbers like "241" or
+// empty for the terminal's default.
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nextthang/lurkmode/internal/config"
+)
+
+type Badges struct {
+	Broadcaster string `json:"broadcaster"`
+	VIP         string `json:"vip"`
+	Moderator   string `json:"moderator"`
+	Subscriber  string `json:"subscriber"`
+}
+
+type Theme struct {
+	// Base is the built-in theme a theme file starts from, dark unless set.
+	Base string `json:"base,omitempty"`
+
+	// Background is the terminal background the theme is made for. Names are
+	// lightened or darkened until they are readable on it.
+	Background string `json:"background"`
+
+	// Accent is the background of the header and the colour of the border.
+	Accent     string `json:"accent"`
+	HeaderText string `json:"header_text"`
+	FooterText string `json:"footer_text"`
+	// Error is the colour of errors and warnings in the footer.
+	Error     string `json:"error"`
+	Timestamp string `json:"timestamp"`
+	// Dimmed is the colour of deleted messages, replies and moderation.
+	Dimmed string `json:"dimmed"`
+	// Notice is the background of subs, raids and other user notices.
+	Notice string `json:"notice"`
+	// Selected is the background of the selected message. Without one, the
+	// selected message is shown reversed.
+	Selected string `json:"selected"`
+	// Mention is the background of messages that mention us or match a
+	// highlight. Without one, they are shown bold.
+	Mention string `json:"mention"`
+	// Highlight and HighlightText colour search matches. Without a
+	// highlight colour, matches are shown reversed.
+	Highlight     string `json:"highlight"`
+	HighlightText string `json:"highlight_text"`
+	// Emote is the colour of emotes shown as text.
+	Emote  string `json:"emote"`
+	Badges Badges `json:"badges"`
+	// UserColors shows names in the colours users picked for themselves.
+	UserColors bool `json:"user_colors"`
+}
+
+var builtin = map[string]Theme{
+	"dark": {
+		Background:    "#0e0e10",
+		Accent:        "#6441a5",
+		HeaderText:    "15",
+		FooterText:    "241",
+		Error:         "#e81815",
+		Timestamp:     "247",
+		Dimmed:        "241",
+		Notice:        "#1f1f23",
+		Selected:      "#3a3a3d",
+		Mention:       "#4d1f2a",
+		Highlight:     "#f7d046",
+		HighlightText: "0",
+		Emote:         "#a970ff",
+		Badges: Badges{
+			Broadcaster: "#e81815",
+			VIP:         "#e005b9",
+			Moderator:   "#00ad03",
+			Subscriber:  "#6441a5",
+		},
+		UserColors: true,
+	},
+	"light": {
+		Background:    "#ffffff",
+		Accent:        "#9146ff",
+		HeaderText:    "#ffffff",
+		FooterText:    "#53535f",
+		Error:         "#e91916",
+		Timestamp:     "#53535f",
+		Dimmed:        "#7a7a85",
+		Notice:        "#efeff1",
+		Selected:      "#dad8de",
+		Mention:       "#fde2e7",
+		Highlight:     "#f7d046",
+		HighlightText: "#0e0e10",
+		Emote:         "#772ce8",
+		Badges: Badges{
+			Broadcaster: "#e91916",
+			VIP:         "#c00096",
+			Moderator:   "#008a02",
+			Subscriber:  "#5c16c5",
+		},
+		UserColors: true,
+	},
+	"high-contrast": {
+		Background:    "#000000",
+		Accent:        "#ffff00",
+		HeaderText:    "#000000",
+		FooterText:    "#ffffff",
+		Error:         "#ff5555",
+		Timestamp:     "#ffffff",
+		Dimmed:        "#c0c0c0",
+		Notice:        "#000080",
+		Selected:      "#005f5f",
+		Mention:       "#5f0000",
+		Highlight:     "#ffff00",
+		HighlightText: "#000000",
+		Emote:         "#00ffff",
+		Badges: Badges{
+			Broadcaster: "#ff5555",
+			VIP:         "#ff79ff",
+			Moderator:   "#55ff55",
+			Subscriber:  "#bd93f9",
+		},
+		UserColors: true,
+	},
+	"monochrome": {},
+}
+
+const DefaultName = "dark"
+
+// Names returns the names of the built-in themes.
+func Names() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func Default() Theme {
+	return builtin[DefaultName]
+}
+
+// Dir is where theme files are looked up by name.
+func Dir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "themes"), nil
+}
+
+// Load returns the built-in theme of that name, or reads a theme file. Theme
+// files are given by path or by name, for <config dir>/themes/NAME.json.
+func Load(name string) (Theme, error) {
+	if name == "" {
+		return Default(), nil
+	}
+	if t, ok := builtin[name]; ok {
+		return t, nil
+	}
+
+	path := name
+	if !strings.ContainsRune(name, filepath.Separator) && filepath.Ext(name) == "" {
+		dir, err := Dir()
+		if err != nil {
+			return Theme{}, err
+		}
+		path = filepath.Join(dir, name+".json")
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && path != name {
+		return Theme{}, fmt.Errorf("unknown theme %q, expected one of %s or a theme file", name, strings.Join(Names(), ", "))
+	}
+	if err != nil {
+		return Theme{}, err
+	}
+	return parse(path, data)
+}
+
+func parse(path string, data []byte) (Theme, error) {
+	var base struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(data, &base); err != nil {
+		return Theme{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if base.Base == "" {
+		base.Base = DefaultName
+	}
+	t, ok := builtin[base.Base]
+	if !ok {
+		return Theme{}, fmt.Errorf("parsing %s: unknown base theme %q", path, base.Base)
+	}
+
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Theme{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,191 @@
+package twitch
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gempir/go-twitch-irc/v4"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateConnected
+	StateReconnecting
+	StateDisconnected
+)
+
+// Status is a change of the connection, or of the channels joined over it.
+type Status struct {
+	State ConnectionState
+	// Channel is set when a channel was joined.
+	Channel string
+	// Attempt counts the reconnects since the connection was lost, RetryIn
+	// is how long until the next one.
+	Attempt int
+	RetryIn time.Duration
+	// Err is why the connection was lost, if known.
+	Err error
+}
+
+func (s Status) String() string {
+	switch s.State {
+	case StateConnecting:
+		return "connecting…"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		if s.RetryIn > 0 {
+			return fmt.Sprintf("reconnecting in %s (attempt %d)", s.RetryIn.Round(time.Second), s.Attempt)
+		}
+		return "reconnecting…"
+	default:
+		if s.Err != nil {
+			return "disconnected: " + s.Err.Error()
+		}
+		return "disconnected"
+	}
+}
+
+// OnStatus sets a function that is called with every change of the
+// connection. It must be set before connecting.
+func (c *Client) OnStatus(fn func(Status)) {
+	c.onStatus = fn
+}
+
+func (c *Client) setStatus(status Status) {
+	if c.onStatus != nil {
+		c.onStatus(status)
+	}
+}
+
+// reconnectDelay doubles with every attempt, up to maxReconnectDelay.
+func reconnectDelay(attempt int) time.Duration {
+	delay := minReconnectDelay
+	for i := 1; i < attempt && delay < maxReconnectDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxReconnectDelay)
+}
+
+// Connect connects to Twitch and keeps reconnecting, with backoff, when the
+// connection is lost, until Disconnect is called or the login is rejected.
+// go-twitch-irc redials on its own, the relay reports the lost connections
+// and waits out the backoff. The client can't connect again once Connect
+// returned.
+func (c *Client) Connect() error {
+	defer c.messages.Close()
+	defer c.stopReconnecting()
+
+	c.setStatus(Status{State: StateConnecting})
+	err := c.client.Connect()
+	if c.stopped() || errors.Is(err, twitch.ErrClientDisconnected) {
+		c.setStatus(Status{State: StateDisconnected})
+		return nil
+	}
+	c.setStatus(Status{State: StateDisconnected, Err: err})
+	return err
+}
+
+// connectionLost is called by the relay when a connection ended or failed.
+func (c *Client) connectionLost(err error) {
+	if c.stopped() {
+		return
+	}
+
+	c.mutex.Lock()
+	connected := c.connected
+	if connected {
+		// The connection worked before, so start over with the backoff.
+		c.attempt = 0
+	}
+	c.connected = false
+	c.attempt++
+	c.lastErr = err
+	c.mutex.Unlock()
+
+	if connected {
+		c.setStatus(Status{State: StateReconnecting, Err: err})
+	}
+}
+
+// waitToReconnect is called by the relay before it dials the server. It
+// waits out the backoff after a lost connection and reports false once
+// Disconnect was called.
+func (c *Client) waitToReconnect() bool {
+	c.mutex.Lock()
+	attempt, err := c.attempt, c.lastErr
+	c.mutex.Unlock()
+	if attempt == 0 {
+		return !c.stopped()
+	}
+
+	delay := reconnectDelay(attempt)
+	c.setStatus(Status{State: StateReconnecting, Attempt: attempt, RetryIn: delay, Err: err})
+	select {
+	case <-time.After(delay):
+	case <-c.stop:
+		return false
+	}
+	c.setStatus(Status{State: StateReconnecting, Attempt: attempt})
+	return true
+}
+
+func (c *Client) handleConnect() {
+	if c.stopped() {
+		// Disconnect was called while we were still connecting.
+		c.client.Disconnect()
+		return
+	}
+
+	// go-twitch-irc rejoins every channel it was told to join on connecting,
+	// which are the channels we keep in sync with it.
+	c.mutex.Lock()
+	c.connected = true
+	c.attempt = 0
+	c.mutex.Unlock()
+
+	c.setStatus(Status{State: StateConnected})
+}
+
+func (c *Client) handleSelfJoin(msg twitch.UserJoinMessage) {
+	c.setStatus(Status{State: StateConnected, Channel: msg.Channel})
+}
+
+func (c *Client) handleReconnect(twitch.ReconnectMessage) {
+	c.setStatus(Status{State: StateReconnecting})
+}
+
+func (c *Client) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopReconnecting stops waiting out the backoff and closes the relay.
+func (c *Client) stopReconnecting() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		// Without the relay, go-twitch-irc fails to reconnect and gives up.
+		c.relay.Close()
+	})
+}
+
+// Disconnect closes the connection and stops reconnecting.
+func (c *Client) Disconnect() error {
+	c.stopReconnecting()
+	if err := c.client.Disconnect(); err != nil && !errors.Is(err, twitch.ErrConnectionIsNotOpen) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package twitch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nextthang/lurkmode/internal/emotes"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/twitch/twitchtest"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+const timeout = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	// Joining a channel loads its emotes, which must not reach the real APIs.
+	emotes.SetProviders()
+	m.Run()
+}
+
+func newServer(t *testing.T) *twitchtest.Server {
+	t.Helper()
+
+	server, err := twitchtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// connect connects a client to the server and returns the statuses it
+// reports and the error Connect returns.
+func connect(t *testing.T, server *twitchtest.Server, options Options, channels ...string) (*Client, *queue.Queue[message.Message], chan Status, chan error) {
+	t.Helper()
+
+	messageQueue := queue.New[message.Message](100)
+	options.Address = server.Addr()
+	client, err := NewClient(messageQueue, options, channels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(chan Status, 100)
+	client.OnStatus(func(status Status) { statuses <- status })
+
+	done := make(chan error, 1)
+	returned := make(chan struct{})
+	go func() {
+		done <- client.Connect()
+		close(returned)
+	}()
+	t.Cleanup(func() {
+		client.Disconnect()
+		<-returned
+	})
+	return client, messageQueue, statuses, done
+}
+
+// waitStatus waits for a status that matches and returns it.
+func waitStatus(t *testing.T, statuses chan Status, match func(Status) bool) Status {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case status := <-statuses:
+			if match(status) {
+				return status
+			}
+		case <-deadline:
+			t.Fatal("the status did not arrive in time")
+		}
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+	for _, test := range tests {
+		if got := reconnectDelay(test.attempt); got != test.want {
+			t.Errorf("attempt %d: got %s, want %s", test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestClientRejoinsAfterReconnect(t *testing.T) {
+	server := newServer(t)
+	_, _, statuses, _ := connect(t, server, Options{NoTLS: true}, "lurkmode")
+	waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+
+	server.Reconnect()
+	status := waitStatus(t, statuses, func(s Status) bool { return s.State != StateConnected })
+	if status.State != StateReconnecting {
+		t.Fatalf("got %s, want reconnecting", status)
+	}
+	status = waitStatus(t, statuses, func(s Status) bool { return s.RetryIn > 0 })
+	if status.Attempt != 1 || status.RetryIn != minReconnectDelay {
+		t.Errorf("got attempt %d in %s, want attempt 1 in %s", status.Attempt, status.RetryIn, minReconnectDelay)
+	}
+
+	waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+	if err := server.WaitJoined("lurkmode", timeout); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientBacksOffWhileTheServerIsDown(t *testing.T) {
+	server := newServer(t)
+	client, _, statuses, done := connect(t, server, Options{NoTLS: true}, "lurkmode")
+	waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+
+	server.Close()
+	for attempt := 1; attempt <= 2; attempt++ {
+		status := waitStatus(t, statuses, func(s Status) bool { return s.RetryIn > 0 })
+		if want := reconnectDelay(attempt); status.Attempt != attempt || status.RetryIn != want {
+			t.Errorf("got attempt %d in %s, want attempt %d in %s", status.Attempt, status.RetryIn, attempt, want)
+		}
+		if attempt == 1 && status.Err != nil {
+			// The server hung up, which is no error.
+			t.Errorf("got error %v for a lost connection", status.Err)
+		}
+		if attempt == 2 && status.Err == nil {
+			t.Error("got no error for a refused connection")
+		}
+	}
+
+	// Disconnecting doesn't wait out the backoff.
+	client.Disconnect()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Connect: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Connect did not return after Disconnect")
+	}
+	waitStatus(t, statuses, func(s Status) bool { return s.State == StateDisconnected })
+}
@@ -1,74 +1,290 @@
 package twitch
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/emotes"
 	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/pkg/queue"
 )
 
+var (
+	ErrNotAuthenticated = errors.New("sending messages requires logging in")
+	ErrEmptyMessage     = errors.New("message is empty")
+)
+
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+type Options struct {
+	Username   string
+	OAuthToken string
+	// Address is the host:port of the IRC server, Twitch's unless set.
+	Address string
+	// NoTLS connects to the server without TLS.
+	NoTLS bool
+	// CAFile is a PEM file with the certificates to trust for the server
+	// instead of the system ones.
+	CAFile string
+}
+
+func (o Options) authenticated() bool {
+	return o.Username != "" && o.OAuthToken != ""
+}
+
+const (
+	defaultAddress      = "irc.chat.twitch.tv:6697"
+	defaultPlainAddress = "irc.chat.twitch.tv:6667"
+)
+
+// stallWarning is how long messages may wait for the UI before we log that it
+// seems to be stuck.
+const stallWarning = 10 * time.Second
+
 type Client struct {
-	client      *twitch.Client
-	channels    []string
-	messageChan chan<- message.Message
+	client           *twitch.Client
+	mutex            sync.Mutex
+	channels         []string
+	messages         *queue.Queue[message.Message]
+	authenticated    bool
+	username         string
+	rateLimiter      rateLimiter
+	userStates       map[string]twitch.User
+	lastStallWarning time.Time
+	onStatus         func(Status)
+	connected        bool
+	// attempt counts the failed connections since the last one that worked,
+	// lastErr is why the last one failed.
+	attempt  int
+	lastErr  error
+	stop     chan struct{}
+	stopOnce sync.Once
+	relay    *relay
 }
 
 type messageConstraint interface {
-	twitch.PrivateMessage | twitch.UserNoticeMessage
+	twitch.PrivateMessage | twitch.UserNoticeMessage | twitch.ClearChatMessage | twitch.ClearMessage
 }
 
-func makeMessageHandler[T messageConstraint](ch chan<- message.Message) func(T) {
+func makeMessageHandler[T messageConstraint](c *Client) func(T) {
 	return func(msg T) {
 		var i any = &msg
 		parsedMessage := message.NewMessage(i.(twitch.Message))
 		if parsedMessage == nil {
 			return
 		}
-		select {
-		case ch <- parsedMessage:
-			// Do nothing, message sent successfully
-		case <-time.After(10 * time.Second):
-			// TODO: Ths indicates that the application is locked up. Maybe let's find a way to recover from this somehow?
-			panic("message channel is full for 10 seconds, this seems like like an unrecoverable state")
+		// Never block here, that would stall the connection and get us
+		// disconnected. If the UI can't keep up, the oldest messages go.
+		if !c.messages.Push(parsedMessage) {
+			c.checkStall()
 		}
 	}
 }
 
-func NewClient(messageChan chan<- message.Message, channels ...string) *Client {
-	twitchClient := twitch.NewAnonymousClient()
+// checkStall logs, at most every stallWarning, when the UI stopped taking
+// messages.
+func (c *Client) checkStall() {
+	stalled := c.messages.Stalled()
+	if stalled < stallWarning {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if time.Since(c.lastStallWarning) < stallWarning {
+		return
+	}
+	c.lastStallWarning = time.Now()
+	log.Printf("The UI has not taken any messages for %s, %d messages were dropped so far", stalled.Round(time.Second), c.messages.Dropped())
+}
+
+// NewClient returns a client that pushes the messages of the channels to the
+// queue once connected.
+func NewClient(messages *queue.Queue[message.Message], options Options, channels ...string) (*Client, error) {
+	var twitchClient *twitch.Client
+	if options.authenticated() {
+		// Twitch echoes our JOINs with the lowercase login, which
+		// go-twitch-irc compares to the name we log in with.
+		twitchClient = twitch.NewClient(strings.ToLower(options.Username), options.OAuthToken)
+	} else {
+		twitchClient = twitch.NewAnonymousClient()
+	}
 	if twitchClient == nil {
-		return nil
+		return nil, errors.New("creating the IRC client failed")
 	}
 
-	twitchClient.OnPrivateMessage(makeMessageHandler[twitch.PrivateMessage](messageChan))
-	twitchClient.OnUserNoticeMessage(makeMessageHandler[twitch.UserNoticeMessage](messageChan))
+	address := options.Address
+	var config *tls.Config
+	switch {
+	case options.NoTLS:
+		if address == "" {
+			address = defaultPlainAddress
+		}
+	case options.CAFile != "":
+		var err error
+		if config, err = loadCAFile(options.CAFile); err != nil {
+			return nil, fmt.Errorf("loading CA file: %w", err)
+		}
+	default:
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if address == "" {
+		address = defaultAddress
+	}
 
+	channels = slices.Clone(channels)
+	for i, channel := range channels {
+		channels[i] = strings.ToLower(channel)
+	}
 	twitchClient.Join(channels...)
 
-	return &Client{
-		client:      twitchClient,
-		channels:    channels,
-		messageChan: messageChan,
+	client := &Client{
+		client:        twitchClient,
+		channels:      channels,
+		messages:      messages,
+		authenticated: options.authenticated(),
+		username:      strings.ToLower(options.Username),
+		userStates:    map[string]twitch.User{},
+		stop:          make(chan struct{}),
+	}
+	relay, err := newRelay(address, config, client.waitToReconnect, client.connectionLost)
+	if err != nil {
+		return nil, fmt.Errorf("starting relay: %w", err)
+	}
+	client.relay = relay
+	twitchClient.IrcAddress = relay.Addr()
+	twitchClient.TLS = false
+	twitchClient.SetupCmd = relay.setupCmd
+
+	twitchClient.OnPrivateMessage(makeMessageHandler[twitch.PrivateMessage](client))
+	twitchClient.OnUserNoticeMessage(makeMessageHandler[twitch.UserNoticeMessage](client))
+	twitchClient.OnClearChatMessage(makeMessageHandler[twitch.ClearChatMessage](client))
+	twitchClient.OnClearMessage(makeMessageHandler[twitch.ClearMessage](client))
+	twitchClient.OnUserStateMessage(client.handleUserState)
+	twitchClient.OnConnect(client.handleConnect)
+	twitchClient.OnSelfJoinMessage(client.handleSelfJoin)
+	twitchClient.OnReconnectMessage(client.handleReconnect)
+	twitchClient.OnRoomStateMessage(func(msg twitch.RoomStateMessage) {
+		go emotes.LoadChannel(msg.RoomID)
+	})
+
+	return client, nil
+}
+
+func (c *Client) handleUserState(msg twitch.UserStateMessage) {
+	user := msg.User
+	// USERSTATE carries neither user-id nor room-id, which makes go-twitch-irc
+	// think that we are the broadcaster everywhere. Trust the badges instead.
+	_, user.IsBroadcaster = user.Badges["broadcaster"]
+	_, user.IsVip = user.Badges["vip"]
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.userStates[msg.Channel] = user
+}
+
+func (c *Client) Authenticated() bool {
+	return c.authenticated
+}
+
+// Say sends a message to the given channel. Twitch does not echo our own
+// messages back to us, so the sent message is returned for local display.
+func (c *Client) Say(channel, text string) (message.Message, error) {
+	if !c.authenticated {
+		return nil, ErrNotAuthenticated
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptyMessage
+	}
+	channel = strings.ToLower(channel)
+
+	c.mutex.Lock()
+	user, ok := c.userStates[channel]
+	c.mutex.Unlock()
+	if !ok {
+		user = twitch.User{Name: c.username, DisplayName: c.username}
+	}
+
+	if ok, retryAfter := c.rateLimiter.Allow(user.IsMod || user.IsBroadcaster); !ok {
+		return nil, &RateLimitError{RetryAfter: retryAfter}
 	}
+
+	c.client.Say(channel, text)
+
+	now := time.Now()
+	return message.NewMessage(&twitch.PrivateMessage{
+		User:    user,
+		Raw:     localEchoRaw(user, channel, text, now),
+		Channel: channel,
+		Message: text,
+		Time:    now,
+	}), nil
 }
 
-func (c *Client) Connect() error {
-	defer close(c.messageChan)
-	err := c.client.Connect()
-	if err != nil && !errors.Is(err, twitch.ErrClientDisconnected) {
-		return err
+// localEchoRaw builds the IRC line Twitch would have sent us for one of our
+// own messages, so that they can be stored like any other message.
+func localEchoRaw(user twitch.User, channel, text string, sentAt time.Time) string {
+	badges := make([]string, 0, len(user.Badges))
+	for name, version := range user.Badges {
+		badges = append(badges, fmt.Sprintf("%s/%d", name, version))
 	}
-	return nil
+	slices.Sort(badges)
+
+	return fmt.Sprintf("@badges=%s;color=%s;display-name=%s;tmi-sent-ts=%d;user-id=%s :%s!%s@%s.tmi.twitch.tv PRIVMSG #%s :%s",
+		strings.Join(badges, ","), user.Color, user.DisplayName, sentAt.UnixMilli(), user.ID,
+		user.Name, user.Name, user.Name, channel, text)
 }
 
-func (c *Client) Disconnect() error {
-	return c.client.Disconnect()
+func (c *Client) Channels() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Clone(c.channels)
 }
 
 func (c *Client) AddChannel(channel string) {
+	channel = strings.ToLower(channel)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if slices.Contains(c.channels, channel) {
+		return
+	}
 	c.channels = append(c.channels, channel)
 	if c.client != nil {
 		c.client.Join(channel)
 	}
 }
+
+func (c *Client) RemoveChannel(channel string) {
+	channel = strings.ToLower(channel)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	index := slices.Index(c.channels, channel)
+	if index < 0 {
+		return
+	}
+	c.channels = slices.Delete(c.channels, index, index+1)
+	if c.client != nil {
+		c.client.Depart(channel)
+	}
+}
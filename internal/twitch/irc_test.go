@@ -0,0 +1,146 @@
+package twitch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/internal/twitch/twitchtest"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+func pop(t *testing.T, messageQueue *queue.Queue[message.Message]) message.Message {
+	t.Helper()
+
+	received := make(chan message.Message, 1)
+	go func() {
+		msg, _ := messageQueue.Pop()
+		received <- msg
+	}()
+	select {
+	case msg := <-received:
+		if msg == nil {
+			t.Fatal("the queue was closed")
+		}
+		return msg
+	case <-time.After(timeout):
+		t.Fatal("no message arrived in time")
+		return nil
+	}
+}
+
+func TestClientReceivesMessages(t *testing.T) {
+	server := newServer(t)
+	_, messageQueue, _, _ := connect(t, server, Options{NoTLS: true}, "LurkMode")
+	if err := server.WaitJoined("lurkmode", timeout); err != nil {
+		t.Fatal(err)
+	}
+
+	viewer := twitchtest.User{DisplayName: "Viewer", Color: "#1E90FF", Badges: "subscriber/12"}
+	if _, err := server.PrivMsg("lurkmode", viewer, "hello chat"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.UserNotice("lurkmode", viewer, "resub", "Viewer subscribed for 12 months!", "still here", map[string]string{"cumulative-months": "12"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ClearChat("lurkmode", "viewer", 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		kind message.Kind
+		text string
+	}{
+		{message.KindChat, "hello chat"},
+		{message.KindSub, "still here"},
+		{message.KindModeration, ""},
+	}
+	for _, w := range want {
+		msg := pop(t, messageQueue)
+		if msg.Kind() != w.kind || msg.Text() != w.text || msg.ChannelName() != "lurkmode" {
+			t.Errorf("got %v %q in #%s, want %v %q in #lurkmode", msg.Kind(), msg.Text(), msg.ChannelName(), w.kind, w.text)
+		}
+	}
+}
+
+func TestClientTrustsCAFile(t *testing.T) {
+	server, err := twitchtest.NewTLSServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, server.Certificate(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("with CA file", func(t *testing.T) {
+		_, _, statuses, _ := connect(t, server, Options{CAFile: caFile}, "lurkmode")
+		waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+	})
+
+	t.Run("without CA file", func(t *testing.T) {
+		_, _, statuses, _ := connect(t, server, Options{}, "lurkmode")
+		status := waitStatus(t, statuses, func(s Status) bool { return s.RetryIn > 0 })
+		if status.Err == nil || !strings.Contains(status.Err.Error(), "certificate") {
+			t.Errorf("got error %v, want one about the certificate", status.Err)
+		}
+	})
+}
+
+func TestClientSays(t *testing.T) {
+	server := newServer(t)
+	client, _, statuses, _ := connect(t, server, Options{Username: "LurkBot", OAuthToken: "oauth:token", NoTLS: true}, "lurkmode")
+	waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+
+	msg, err := client.Say("LurkMode", "  hi there ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text() != "hi there" || msg.Sender().Name != "lurkbot" {
+		t.Errorf("got %q from %s, want the local echo of our message", msg.Text(), msg.Sender().Name)
+	}
+	if _, err := server.WaitReceived("PRIVMSG #lurkmode :hi there", timeout); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := client.Say("lurkmode", " "); !errors.Is(err, ErrEmptyMessage) {
+		t.Errorf("got %v, want ErrEmptyMessage", err)
+	}
+}
+
+func TestAnonymousClientCanNotSay(t *testing.T) {
+	client, err := NewClient(queue.New[message.Message](1), Options{}, "lurkmode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+
+	if _, err := client.Say("lurkmode", "hi"); !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("got %v, want ErrNotAuthenticated", err)
+	}
+}
+
+func TestClientJoinsAndParts(t *testing.T) {
+	server := newServer(t)
+	client, _, statuses, _ := connect(t, server, Options{NoTLS: true}, "lurkmode")
+	waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+
+	client.AddChannel("OtherChannel")
+	if err := server.WaitJoined("otherchannel", timeout); err != nil {
+		t.Fatal(err)
+	}
+	client.RemoveChannel("lurkmode")
+	if _, err := server.WaitReceived("PART #lurkmode", timeout); err != nil {
+		t.Error(err)
+	}
+	if got := client.Channels(); !slices.Equal(got, []string{"otherchannel"}) {
+		t.Errorf("got channels %v, want [otherchannel]", got)
+	}
+}
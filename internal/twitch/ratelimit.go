@@ -0,0 +1,53 @@
+package twitch
+
+import (
+	"sync"
+	"time"
+)
+
+// Twitch allows 20 messages per 30 seconds for regular users across all
+// channels, and 100 messages per 30 seconds in channels where the user is a
+// moderator or the broadcaster.
+const (
+	rateLimitWindow          = 30 * time.Second
+	rateLimitRegular         = 20
+	rateLimitModerator       = 100
+	rateLimitSafetyAllowance = 1
+)
+
+type rateLimiter struct {
+	mutex sync.Mutex
+	sent  []time.Time
+}
+
+func (r *rateLimiter) prune(now time.Time) {
+	cutoff := now.Add(-rateLimitWindow)
+	i := 0
+	for i < len(r.sent) && !r.sent[i].After(cutoff) {
+		i++
+	}
+	r.sent = r.sent[i:]
+}
+
+// Allow records a message if it fits into the window and reports whether it
+// may be sent. If not, it also returns how long to wait before trying again.
+func (r *rateLimiter) Allow(elevated bool) (bool, time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.prune(now)
+
+	limit := rateLimitRegular
+	if elevated {
+		limit = rateLimitModerator
+	}
+	limit -= rateLimitSafetyAllowance
+
+	if len(r.sent) >= limit {
+		return false, r.sent[len(r.sent)-limit].Add(rateLimitWindow).Sub(now)
+	}
+
+	r.sent = append(r.sent, now)
+	return true, 0
+}
@@ -0,0 +1,83 @@
+package twitch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gempir/go-twitch-irc/v4"
+	"github.com/nextthang/lurkmode/internal/message"
+	"github.com/nextthang/lurkmode/pkg/queue"
+)
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		elevated bool
+		allowed  int
+	}{
+		{"regular", false, rateLimitRegular - rateLimitSafetyAllowance},
+		{"moderator", true, rateLimitModerator - rateLimitSafetyAllowance},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var limiter rateLimiter
+			for i := range test.allowed {
+				if ok, _ := limiter.Allow(test.elevated); !ok {
+					t.Fatalf("message %d was not allowed", i+1)
+				}
+			}
+			ok, retryAfter := limiter.Allow(test.elevated)
+			if ok {
+				t.Fatalf("message %d was allowed", test.allowed+1)
+			}
+			if retryAfter <= 0 || retryAfter > rateLimitWindow {
+				t.Errorf("got retry after %s, want up to %s", retryAfter, rateLimitWindow)
+			}
+		})
+	}
+}
+
+func TestRateLimiterForgetsOldMessages(t *testing.T) {
+	limiter := rateLimiter{sent: make([]time.Time, rateLimitRegular)}
+	for i := range limiter.sent {
+		limiter.sent[i] = time.Now().Add(-rateLimitWindow - time.Second)
+	}
+	if ok, _ := limiter.Allow(false); !ok {
+		t.Error("messages older than the window still count")
+	}
+}
+
+func TestSayRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    twitch.User
+		allowed int
+	}{
+		{"regular", twitch.User{Name: "lurkbot"}, rateLimitRegular - rateLimitSafetyAllowance},
+		// Twitch doesn't raise the limit for VIPs.
+		{"VIP", twitch.User{Name: "lurkbot", IsVip: true}, rateLimitRegular - rateLimitSafetyAllowance},
+		{"moderator", twitch.User{Name: "lurkbot", IsMod: true}, rateLimitModerator - rateLimitSafetyAllowance},
+		{"broadcaster", twitch.User{Name: "lurkbot", IsBroadcaster: true}, rateLimitModerator - rateLimitSafetyAllowance},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewClient(queue.New[message.Message](1), Options{Username: "lurkbot", OAuthToken: "oauth:token"}, "lurkmode")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Disconnect()
+			client.userStates["lurkmode"] = test.user
+
+			for i := range test.allowed {
+				if _, err := client.Say("lurkmode", "hi"); err != nil {
+					t.Fatalf("message %d: %v", i+1, err)
+				}
+			}
+			var rateLimitErr *RateLimitError
+			if _, err := client.Say("lurkmode", "hi"); !errors.As(err, &rateLimitErr) {
+				t.Errorf("got %v, want a RateLimitError", err)
+			}
+		})
+	}
+}
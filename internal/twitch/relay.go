@@ -0,0 +1,172 @@
+package twitch
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	relayDialTimeout = 10 * time.Second
+	// relayLoginTimeout is how long a peer has to send the setup command.
+	relayLoginTimeout = 5 * time.Second
+)
+
+// loadCAFile returns a TLS config trusting only the certificates of a PEM file.
+func loadCAFile(path string) (*tls.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// relay accepts plaintext connections on localhost and forwards them to the
+// server. go-twitch-irc redials right away and without telling us when a
+// connection is lost, so the client always connects through the relay, which
+// reports lost connections and holds back the next dial for the backoff. It
+// also dials with the certificates of a CA file, which go-twitch-irc has no
+// way to set.
+//
+// Anyone on the machine can connect to localhost, so a peer is only forwarded
+// once it sent the setup command, which holds a random token and which
+// go-twitch-irc sends first on every connection, and only one connection is
+// forwarded at a time.
+type relay struct {
+	listener net.Listener
+	address  string
+	setupCmd string
+	// config is nil to connect to the server without TLS.
+	config *tls.Config
+	// wait is called before dialing the server and reports whether to dial.
+	wait func() bool
+	// lost is called when a forwarded connection ended or the server could
+	// not be reached.
+	lost func(err error)
+	// forwarding is held while a connection is forwarded.
+	forwarding sync.Mutex
+}
+
+func newRelay(address string, config *tls.Config, wait func() bool, lost func(error)) (*relay, error) {
+	if config != nil {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r := &relay{
+		listener: listener,
+		address:  address,
+		setupCmd: fmt.Sprintf("LURKMODE %x", token),
+		config:   config,
+		wait:     wait,
+		lost:     lost,
+	}
+	go r.serve()
+	return r, nil
+}
+
+func (r *relay) Addr() string {
+	return r.listener.Addr().String()
+}
+
+func (r *relay) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Relay stopped: %v", err)
+			}
+			return
+		}
+		go r.forward(conn)
+	}
+}
+
+func (r *relay) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: relayDialTimeout}
+	if r.config == nil {
+		return dialer.Dial("tcp", r.address)
+	}
+	return tls.DialWithDialer(dialer, "tcp", r.address, r.config)
+}
+
+// login reads the setup command from a peer and reports whether it was ours.
+// The reader keeps what the peer sent after it.
+func (r *relay) login(conn net.Conn) (*bufio.Reader, bool) {
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(relayLoginTimeout))
+	line, err := reader.ReadSlice('\n')
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, false
+	}
+	line = bytes.TrimRight(line, "\r\n")
+	return reader, subtle.ConstantTimeCompare(line, []byte(r.setupCmd)) == 1
+}
+
+func (r *relay) forward(conn net.Conn) {
+	defer conn.Close()
+
+	reader, ok := r.login(conn)
+	if !ok {
+		log.Printf("Relay dropped a connection from %s that is not ours", conn.RemoteAddr())
+		return
+	}
+	// go-twitch-irc may redial before the relay is done with the last
+	// connection.
+	r.forwarding.Lock()
+	defer r.forwarding.Unlock()
+
+	if !r.wait() {
+		return
+	}
+	server, err := r.dial()
+	if err != nil {
+		log.Printf("Failed to connect to %s: %v", r.address, err)
+		r.lost(err)
+		return
+	}
+	defer server.Close()
+
+	go func() {
+		io.Copy(server, reader)
+		server.Close()
+	}()
+	if _, err = io.Copy(conn, server); errors.Is(err, net.ErrClosed) {
+		// go-twitch-irc hung up, e.g. to follow a RECONNECT.
+		err = nil
+	}
+	r.lost(err)
+}
+
+func (r *relay) Close() error {
+	return r.listener.Close()
+}
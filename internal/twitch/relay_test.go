@@ -0,0 +1,78 @@
+package twitch
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gempir/go-twitch-irc/v4"
+)
+
+// dialRelay connects to the relay of the client like some other program
+// would, sends the lines and returns what reading the answer failed with.
+func dialRelay(t *testing.T, client *Client, lines ...string) error {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", client.relay.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n")); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	return err
+}
+
+func TestRelayForwardsOnlyTheClient(t *testing.T) {
+	server := newServer(t)
+	client, _, statuses, _ := connect(t, server, Options{NoTLS: true}, "lurkmode")
+	waitStatus(t, statuses, func(s Status) bool { return s.Channel == "lurkmode" })
+
+	if err := dialRelay(t, client, "NICK intruder", "PRIVMSG #lurkmode :without the token"); !errors.Is(err, io.EOF) {
+		t.Errorf("got %v, want the relay to hang up on a peer without the token", err)
+	}
+	// Even with the token, it waits for the connection of go-twitch-irc to
+	// end first.
+	if err := dialRelay(t, client, client.relay.setupCmd, "NICK intruder", "PRIVMSG #lurkmode :a second connection"); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("got %v, want the relay to hold back a second connection", err)
+	}
+
+	for _, line := range server.Received() {
+		if strings.HasPrefix(line, "LURKMODE") || strings.Contains(line, "intruder") {
+			t.Errorf("the server received %q", line)
+		}
+	}
+	select {
+	case status := <-statuses:
+		t.Errorf("got status %s, want the client to stay connected", status)
+	default:
+	}
+}
+
+func TestConnectClosesTheRelay(t *testing.T) {
+	server := newServer(t)
+	server.RejectLogins()
+	client, _, _, done := connect(t, server, Options{Username: "lurkbot", OAuthToken: "oauth:wrong", NoTLS: true}, "lurkmode")
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, twitch.ErrLoginAuthenticationFailed) {
+			t.Errorf("got %v, want ErrLoginAuthenticationFailed", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("Connect did not return for a rejected login")
+	}
+
+	if conn, err := net.Dial("tcp", client.relay.Addr()); err == nil {
+		conn.Close()
+		t.Error("the relay still accepts connections")
+	}
+}
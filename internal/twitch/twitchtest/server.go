@@ -0,0 +1,425 @@
+// Package twitchtest provides a fake Twitch IRC server, so that the client
+// and the app can be exercised without the network.
+//
+// The server speaks just enough IRC for go-twitch-irc: it acknowledges CAP
+// requests, welcomes every login unless told to reject them, answers PINGs
+// and JOINs and PARTs channels. Tests then push PRIVMSGs, USERNOTICEs,
+// CLEARCHATs and RECONNECTs to the clients that joined a channel.
+package twitchtest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type conn struct {
+	net.Conn
+	mutex    sync.Mutex
+	nick     string
+	channels []string
+}
+
+func (c *conn) send(line string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, err := c.Write([]byte(line + "\r\n"))
+	return err
+}
+
+func (c *conn) joined(channel string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return slices.Contains(c.channels, channel)
+}
+
+type Server struct {
+	listener    net.Listener
+	certificate []byte
+
+	mutex    sync.Mutex
+	conns    map[*conn]struct{}
+	received []string
+	changed  chan struct{}
+	nextID   int
+	// rejectLogins answers logins with the NOTICE Twitch sends for a wrong
+	// token.
+	rejectLogins bool
+}
+
+// NewServer starts a server without TLS on a random port of localhost.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return newServer(listener, nil), nil
+}
+
+// NewTLSServer starts a server with a self-signed certificate for localhost,
+// see Certificate.
+func NewTLSServer() (*Server, error) {
+	certificate, key, err := selfSigned()
+	if err != nil {
+		return nil, err
+	}
+	pair, err := tls.X509KeyPair(certificate, key)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{pair}})
+	if err != nil {
+		return nil, err
+	}
+	return newServer(listener, certificate), nil
+}
+
+func newServer(listener net.Listener, certificate []byte) *Server {
+	s := &Server{
+		listener:    listener,
+		certificate: certificate,
+		conns:       map[*conn]struct{}{},
+		changed:     make(chan struct{}),
+	}
+	go s.serve()
+	return s
+}
+
+// Addr returns the host:port to connect to.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Certificate returns the PEM encoded certificate of a TLS server, to be
+// trusted as CA file.
+func (s *Server) Certificate() []byte {
+	return s.certificate
+}
+
+// Close stops the server and drops every connection.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for c := range s.conns {
+		c.Close()
+	}
+	return err
+}
+
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) serve() {
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: netConn}
+		s.mutex.Lock()
+		s.conns[c] = struct{}{}
+		s.notify()
+		s.mutex.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c *conn) {
+	defer func() {
+		c.Close()
+		s.mutex.Lock()
+		delete(s.conns, c)
+		s.notify()
+		s.mutex.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(c)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		s.mutex.Lock()
+		s.received = append(s.received, line)
+		s.notify()
+		s.mutex.Unlock()
+
+		command, params, _ := strings.Cut(line, " ")
+		switch command {
+		case "CAP":
+			c.send(":tmi.twitch.tv CAP * ACK :" + strings.TrimPrefix(params, "REQ :"))
+		case "NICK":
+			c.mutex.Lock()
+			c.nick = strings.ToLower(params)
+			c.mutex.Unlock()
+			s.mutex.Lock()
+			reject := s.rejectLogins
+			s.mutex.Unlock()
+			if reject {
+				c.send(":tmi.twitch.tv NOTICE * :Login authentication failed")
+				return
+			}
+			for _, numeric := range []string{"001 %s :Welcome, GLHF!", "002 %s :Your host is tmi.twitch.tv", "003 %s :This server is rather new", "004 %s :-", "375 %s :-", "372 %s :You are in a maze of twisty passages, all alike.", "376 %s :>"} {
+				c.send(":tmi.twitch.tv " + fmt.Sprintf(numeric, c.nick))
+			}
+		case "PING":
+			c.send("PONG " + params)
+		case "JOIN":
+			for _, channel := range strings.Split(params, ",") {
+				s.join(c, strings.TrimPrefix(channel, "#"))
+			}
+		case "PART":
+			channel := strings.TrimPrefix(params, "#")
+			c.mutex.Lock()
+			c.channels = slices.DeleteFunc(c.channels, func(joined string) bool { return joined == channel })
+			c.mutex.Unlock()
+			c.send(fmt.Sprintf(":%[1]s!%[1]s@%[1]s.tmi.twitch.tv PART #%[2]s", c.nick, channel))
+		}
+	}
+}
+
+func (s *Server) join(c *conn, channel string) {
+	c.mutex.Lock()
+	if !slices.Contains(c.channels, channel) {
+		c.channels = append(c.channels, channel)
+	}
+	nick := c.nick
+	c.mutex.Unlock()
+
+	c.send(fmt.Sprintf(":%[1]s!%[1]s@%[1]s.tmi.twitch.tv JOIN #%[2]s", nick, channel))
+	c.send(fmt.Sprintf(":%[1]s.tmi.twitch.tv 353 %[1]s = #%[2]s :%[1]s", nick, channel))
+	c.send(fmt.Sprintf(":%[1]s.tmi.twitch.tv 366 %[1]s #%[2]s :End of /NAMES list", nick, channel))
+	c.send(fmt.Sprintf("@emote-only=0;followers-only=-1;r9k=0;room-id=%d;slow=0;subs-only=0 :tmi.twitch.tv ROOMSTATE #%s", roomID(channel), channel))
+
+	s.mutex.Lock()
+	s.notify()
+	s.mutex.Unlock()
+}
+
+// roomID makes up a stable id for a channel.
+func roomID(channel string) int {
+	id := 0
+	for _, r := range channel {
+		id = id*31 + int(r)
+	}
+	return id%100000000 + 1
+}
+
+// Received returns every line the clients sent so far.
+func (s *Server) Received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return slices.Clone(s.received)
+}
+
+// wait waits until check reports true, which is checked with the server
+// locked every time something changed.
+func (s *Server) wait(timeout time.Duration, check func() bool) bool {
+	deadline := time.After(timeout)
+	for {
+		s.mutex.Lock()
+		ok := check()
+		changed := s.changed
+		s.mutex.Unlock()
+		if ok {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// WaitJoined waits until a client joined the channel.
+func (s *Server) WaitJoined(channel string, timeout time.Duration) error {
+	channel = strings.ToLower(channel)
+	ok := s.wait(timeout, func() bool {
+		for c := range s.conns {
+			if c.joined(channel) {
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		return fmt.Errorf("no client joined #%s within %s", channel, timeout)
+	}
+	return nil
+}
+
+// WaitReceived waits until a client sent a line starting with prefix and
+// returns it.
+func (s *Server) WaitReceived(prefix string, timeout time.Duration) (string, error) {
+	var found string
+	ok := s.wait(timeout, func() bool {
+		for _, line := range s.received {
+			if strings.HasPrefix(line, prefix) {
+				found = line
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		return "", fmt.Errorf("no client sent %q within %s", prefix, timeout)
+	}
+	return found, nil
+}
+
+// Send sends a raw line to every client that joined the channel.
+func (s *Server) Send(channel, line string) error {
+	channel = strings.ToLower(channel)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var errs []error
+	for c := range s.conns {
+		if c.joined(channel) {
+			errs = append(errs, c.send(line))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) messageID() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextID++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.nextID)
+}
+
+// User is who sends a message, the login is made up from the display name.
+type User struct {
+	DisplayName string
+	Color       string
+	// Badges are like "moderator/1,subscriber/12".
+	Badges string
+}
+
+func (u User) login() string {
+	return strings.ToLower(u.DisplayName)
+}
+
+func (u User) tags(channel string) []string {
+	return []string{
+		"badges=" + u.Badges,
+		"color=" + u.Color,
+		"display-name=" + u.DisplayName,
+		fmt.Sprintf("room-id=%d", roomID(channel)),
+		fmt.Sprintf("tmi-sent-ts=%d", time.Now().UnixMilli()),
+		fmt.Sprintf("user-id=%d", roomID(u.login())),
+	}
+}
+
+// PrivMsg sends a chat message and returns its id.
+func (s *Server) PrivMsg(channel string, user User, text string) (string, error) {
+	id := s.messageID()
+	tags := append(user.tags(channel), "id="+id)
+	return id, s.Send(channel, fmt.Sprintf("@%s :%[2]s!%[2]s@%[2]s.tmi.twitch.tv PRIVMSG #%s :%s",
+		strings.Join(tags, ";"), user.login(), strings.ToLower(channel), text))
+}
+
+// UserNotice sends a USERNOTICE like a sub or a raid. params are the msg-param
+// tags without their prefix, e.g. "cumulative-months" for msg-param-cumulative-months.
+func (s *Server) UserNotice(channel string, user User, msgID, systemMsg, text string, params map[string]string) error {
+	tags := append(user.tags(channel), "id="+s.messageID(), "login="+user.login(), "msg-id="+msgID, "system-msg="+escapeTag(systemMsg))
+	for name, value := range params {
+		tags = append(tags, "msg-param-"+name+"="+escapeTag(value))
+	}
+	slices.Sort(tags)
+
+	line := fmt.Sprintf("@%s :tmi.twitch.tv USERNOTICE #%s", strings.Join(tags, ";"), strings.ToLower(channel))
+	if text != "" {
+		line += " :" + text
+	}
+	return s.Send(channel, line)
+}
+
+// ClearChat clears the chat, or removes the messages of a user if login is
+// set, for duration or for good if it is zero.
+func (s *Server) ClearChat(channel, login string, duration time.Duration) error {
+	tags := []string{fmt.Sprintf("room-id=%d", roomID(channel)), fmt.Sprintf("tmi-sent-ts=%d", time.Now().UnixMilli())}
+	if login == "" {
+		return s.Send(channel, fmt.Sprintf("@%s :tmi.twitch.tv CLEARCHAT #%s", strings.Join(tags, ";"), strings.ToLower(channel)))
+	}
+	if duration > 0 {
+		tags = append(tags, fmt.Sprintf("ban-duration=%d", int(duration.Seconds())))
+	}
+	tags = append(tags, fmt.Sprintf("target-user-id=%d", roomID(login)))
+	return s.Send(channel, fmt.Sprintf("@%s :tmi.twitch.tv CLEARCHAT #%s :%s", strings.Join(tags, ";"), strings.ToLower(channel), login))
+}
+
+// RejectLogins makes the server reject every login from now on.
+func (s *Server) RejectLogins() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.rejectLogins = true
+}
+
+// Reconnect tells every client to reconnect and hangs up on them, like Twitch
+// does before restarting a server.
+func (s *Server) Reconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for c := range s.conns {
+		c.send(":tmi.twitch.tv RECONNECT")
+		c.Close()
+	}
+}
+
+// escapeTag escapes a tag value as IRCv3 requires.
+func escapeTag(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`).Replace(value)
+}
+
+func selfSigned() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "twitchtest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
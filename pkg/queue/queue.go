@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// Queue is a bounded FIFO queue between a producer that must never block, like
+// a network connection, and a consumer that might fall behind. Once full, Push
+// drops the oldest element to make room.
+type Queue[T any] struct {
+	mutex    sync.Mutex
+	capacity int
+	items    []T
+	dropped  int
+	closed   bool
+	progress time.Time
+	changed  chan struct{}
+	done     chan struct{}
+}
+
+func New[T any](capacity int) *Queue[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &Queue[T]{
+		capacity: capacity,
+		items:    make([]T, 0, capacity),
+		changed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// notify wakes up everyone waiting for the queue to change. It must be called
+// with the queue locked.
+func (q *Queue[T]) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// Push adds an element, dropping the oldest one if the queue is full. It
+// reports whether nothing had to be dropped. Elements pushed after Close are
+// discarded.
+func (q *Queue[T]) Push(item T) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return true
+	}
+	ok := true
+	if len(q.items) == q.capacity {
+		var zero T
+		q.items[0] = zero
+		q.items = q.items[1:]
+		q.dropped++
+		ok = false
+	}
+	if len(q.items) == 0 {
+		q.progress = time.Now()
+	}
+	q.items = append(q.items, item)
+	q.notify()
+	return ok
+}
+
+// PushWait adds an element, waiting for room instead of dropping anything. It
+// returns false if done is closed first.
+func (q *Queue[T]) PushWait(item T, done <-chan struct{}) bool {
+	for {
+		q.mutex.Lock()
+		if q.closed {
+			q.mutex.Unlock()
+			return false
+		}
+		if len(q.items) < q.capacity {
+			if len(q.items) == 0 {
+				q.progress = time.Now()
+			}
+			q.items = append(q.items, item)
+			q.notify()
+			q.mutex.Unlock()
+			return true
+		}
+		changed := q.changed
+		q.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// Pop takes the oldest element, waiting for one if the queue is empty. It
+// returns false once the queue is closed and empty.
+func (q *Queue[T]) Pop() (T, bool) {
+	for {
+		q.mutex.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			var zero T
+			q.items[0] = zero
+			q.items = q.items[1:]
+			q.progress = time.Now()
+			q.notify()
+			q.mutex.Unlock()
+			return item, true
+		}
+		if q.closed {
+			q.mutex.Unlock()
+			var zero T
+			return zero, false
+		}
+		changed := q.changed
+		q.mutex.Unlock()
+
+		<-changed
+	}
+}
+
+// PopBatch waits for an element like Pop, then also takes everything pushed
+// within window after it, so that a burst can be handled at once.
+func (q *Queue[T]) PopBatch(window time.Duration) ([]T, bool) {
+	first, ok := q.Pop()
+	if !ok {
+		return nil, false
+	}
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-q.done:
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	batch := make([]T, 0, len(q.items)+1)
+	batch = append(batch, first)
+	batch = append(batch, q.items...)
+	clear(q.items)
+	q.items = q.items[:0]
+	q.progress = time.Now()
+	q.notify()
+	return batch, true
+}
+
+// Close lets Pop return once the remaining elements are taken.
+func (q *Queue[T]) Close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.notify()
+}
+
+func (q *Queue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.items)
+}
+
+// Dropped returns the number of elements Push dropped so far.
+func (q *Queue[T]) Dropped() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.dropped
+}
+
+// Stalled returns for how long elements have been waiting without the
+// consumer taking any, or zero if the queue is empty.
+func (q *Queue[T]) Stalled() time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.items) == 0 {
+		return 0
+	}
+	return time.Since(q.progress)
+}
@@ -0,0 +1,249 @@
+package queue
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPushDropsOldest(t *testing.T) {
+	tests := []struct {
+		capacity int
+		pushes   int
+		want     []int
+		dropped  int
+	}{
+		{capacity: 3, pushes: 2, want: []int{0, 1}, dropped: 0},
+		{capacity: 3, pushes: 3, want: []int{0, 1, 2}, dropped: 0},
+		{capacity: 3, pushes: 5, want: []int{2, 3, 4}, dropped: 2},
+		{capacity: 0, pushes: 3, want: []int{2}, dropped: 2},
+	}
+	for _, test := range tests {
+		q := New[int](test.capacity)
+		for i := range test.pushes {
+			if ok := q.Push(i); ok != (i < max(test.capacity, 1)) {
+				t.Errorf("capacity %d: push %d reported %v", test.capacity, i, ok)
+			}
+		}
+		if got := q.Dropped(); got != test.dropped {
+			t.Errorf("capacity %d, %d pushes: dropped %d, want %d", test.capacity, test.pushes, got, test.dropped)
+		}
+
+		q.Close()
+		var got []int
+		for {
+			item, ok := q.Pop()
+			if !ok {
+				break
+			}
+			got = append(got, item)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("capacity %d, %d pushes: got %v, want %v", test.capacity, test.pushes, got, test.want)
+		}
+	}
+}
+
+func TestPushAfterClose(t *testing.T) {
+	q := New[int](2)
+	q.Push(1)
+	q.Close()
+	q.Push(2)
+
+	if item, ok := q.Pop(); !ok || item != 1 {
+		t.Errorf("got %d, %v, want the element pushed before Close", item, ok)
+	}
+	if _, ok := q.Pop(); ok {
+		t.Error("got an element pushed after Close")
+	}
+}
+
+func TestCloseWakesPop(t *testing.T) {
+	q := New[int](1)
+	done := make(chan bool)
+	go func() {
+		_, ok := q.Pop()
+		done <- ok
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Pop returned an element from an empty queue")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not return after Close")
+	}
+}
+
+func TestPushWait(t *testing.T) {
+	t.Run("waits for room", func(t *testing.T) {
+		q := New[int](1)
+		q.Push(1)
+
+		done := make(chan bool)
+		go func() { done <- q.PushWait(2, nil) }()
+		select {
+		case <-done:
+			t.Fatal("PushWait did not wait for room")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		q.Pop()
+		if !<-done {
+			t.Error("PushWait failed once there was room")
+		}
+		if item, _ := q.Pop(); item != 2 {
+			t.Errorf("got %d, want 2", item)
+		}
+		if q.Dropped() != 0 {
+			t.Errorf("dropped %d, want 0", q.Dropped())
+		}
+	})
+
+	t.Run("done", func(t *testing.T) {
+		q := New[int](1)
+		q.Push(1)
+
+		stop := make(chan struct{})
+		done := make(chan bool)
+		go func() { done <- q.PushWait(2, stop) }()
+		close(stop)
+		if <-done {
+			t.Error("PushWait succeeded after done was closed")
+		}
+	})
+
+	t.Run("close while waiting", func(t *testing.T) {
+		q := New[int](1)
+		q.Push(1)
+
+		done := make(chan bool)
+		go func() { done <- q.PushWait(2, nil) }()
+		time.Sleep(10 * time.Millisecond)
+		q.Close()
+		select {
+		case ok := <-done:
+			if ok {
+				t.Error("PushWait succeeded on a closed queue")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("PushWait did not return after Close")
+		}
+	})
+}
+
+func TestPopBatch(t *testing.T) {
+	q := New[int](10)
+	go func() {
+		q.Push(0)
+		time.Sleep(5 * time.Millisecond)
+		q.Push(1)
+		q.Push(2)
+	}()
+
+	batch, ok := q.PopBatch(100 * time.Millisecond)
+	if !ok {
+		t.Fatal("PopBatch failed")
+	}
+	if want := []int{0, 1, 2}; !slices.Equal(batch, want) {
+		t.Errorf("got %v, want %v", batch, want)
+	}
+	if q.Len() != 0 {
+		t.Errorf("%d elements left in the queue", q.Len())
+	}
+}
+
+func TestPopBatchReturnsEarlyOnClose(t *testing.T) {
+	q := New[int](10)
+	q.Push(0)
+	q.Push(1)
+	q.Close()
+
+	start := time.Now()
+	batch, ok := q.PopBatch(time.Minute)
+	if !ok || !slices.Equal(batch, []int{0, 1}) {
+		t.Errorf("got %v, %v, want [0 1]", batch, ok)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("PopBatch waited %s for a closed queue", elapsed)
+	}
+	if _, ok := q.PopBatch(time.Minute); ok {
+		t.Error("PopBatch succeeded on a closed and empty queue")
+	}
+}
+
+func TestStalled(t *testing.T) {
+	q := New[int](2)
+	if q.Stalled() != 0 {
+		t.Error("an empty queue is stalled")
+	}
+
+	q.Push(1)
+	time.Sleep(10 * time.Millisecond)
+	if q.Stalled() < 10*time.Millisecond {
+		t.Errorf("stalled for %s, want at least 10ms", q.Stalled())
+	}
+
+	q.Pop()
+	if q.Stalled() != 0 {
+		t.Error("an emptied queue is stalled")
+	}
+}
+
+// TestConcurrent pushes from several producers while a slow consumer takes
+// batches, checking that every element is either received or dropped.
+func TestConcurrent(t *testing.T) {
+	const (
+		producers = 4
+		pushes    = 1000
+	)
+	q := New[int](16)
+
+	received := make(chan []int)
+	go func() {
+		var all []int
+		for {
+			batch, ok := q.PopBatch(time.Millisecond)
+			if !ok {
+				received <- all
+				return
+			}
+			all = append(all, batch...)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pushes {
+				q.Push(p*pushes + i)
+			}
+		}()
+	}
+	wg.Wait()
+	q.Close()
+
+	all := <-received
+	if got := len(all) + q.Dropped(); got != producers*pushes {
+		t.Errorf("received %d and dropped %d of %d elements", len(all), q.Dropped(), producers*pushes)
+	}
+
+	// Every producer's elements arrive in the order they were pushed.
+	last := make([]int, producers)
+	for p := range last {
+		last[p] = -1
+	}
+	for _, item := range all {
+		p, i := item/pushes, item%pushes
+		if i <= last[p] {
+			t.Fatalf("producer %d: element %d arrived after %d", p, i, last[p])
+		}
+		last[p] = i
+	}
+}
@@ -7,6 +7,7 @@ type RingBuffer[T any] struct {
 	capacity int
 	head     int
 	buffer   []T
+	onEvict  func(T)
 }
 
 func NewBuffer[T any](capacity int) *RingBuffer[T] {
@@ -29,10 +30,22 @@ func (b *RingBuffer[T]) Add(message T) {
 		return
 	}
 
+	if b.onEvict != nil {
+		b.onEvict(b.buffer[b.head])
+	}
 	b.buffer[b.head] = message
 	b.head = (b.head + 1) % b.capacity
 }
 
+// OnEvict sets a function that is called with every element that is pushed
+// out of the buffer. It is called with the buffer locked.
+func (b *RingBuffer[T]) OnEvict(fn func(T)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.onEvict = fn
+}
+
 func (b *RingBuffer[T]) Get() []T {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
@@ -49,6 +62,14 @@ func (b *RingBuffer[T]) Get() []T {
 	return result
 }
 
+// Full reports whether adding another element evicts the oldest one.
+func (b *RingBuffer[T]) Full() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return len(b.buffer) == b.capacity
+}
+
 func (b *RingBuffer[T]) Len() int {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()